@@ -6,20 +6,24 @@ import (
 	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/bwmarrin/discordgo"
-	"github.com/hraban/opus"
+	"io"
 	"io/ioutil"
 	"log"
-	"math/rand"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
 
-func downloadAndPlayYT(ctx context.Context, discord *discordgo.Session, channelID, guildID, url string) {
+// Download a YouTube track before queueing it, so it is ready to play as soon
+// as it reaches the front
+func downloadAndPlayYT(ctx context.Context, discord *discordgo.Session, guildID string, track *Track) {
+	channelID := track.ChannelID
 
 	select {
 	case <-ctx.Done():
@@ -28,34 +32,46 @@ func downloadAndPlayYT(ctx context.Context, discord *discordgo.Session, channelI
 	default:
 	}
 
-	botManager.mu.RLock()
-	session, ok := botManager.voiceConnections[guildID]
-	botManager.mu.RUnlock()
-
-	if !ok || session == nil || session.connection == nil {
+	session, ok := getVoiceSession(guildID)
+	if !ok {
 		discord.ChannelMessageSend(channelID, "❌ Not connected to a voice channel.")
 		return
 	}
 
+	discord.ChannelMessageSend(channelID, "⬇️ Downloading YouTube audio...")
+
+	actualFileName, err := downloadYT(ctx, guildID, track.URL)
+	if err != nil {
+		if ctx.Err() != nil {
+			discord.ChannelMessageSend(channelID, "❌ YouTube download cancelled.")
+		} else {
+			discord.ChannelMessageSend(channelID, "❌ "+err.Error())
+		}
+		return
+	}
+
+	discord.ChannelMessageSend(channelID, "🎵 Downloaded YouTube audio, adding it to the queue...")
+	track.Filename = actualFileName
+	enqueueTrack(discord, session, track)
+}
+
+// Download a video's audio to an mp3 temp file tracked for the guild.
+// The queue deletes the file once its track is done
+func downloadYT(ctx context.Context, guildID, url string) (string, error) {
 	// Generate a temp file name (without extension for yt-dlp template)
-	baseFileName := fmt.Sprintf("yt_audio_%d_%d", time.Now().Unix(), rand.Intn(100000))
+	baseFileName := fmt.Sprintf("yt_audio_%d_%d", time.Now().Unix(), tempFileID())
 	outputTemplate := baseFileName + ".%(ext)s"
 
 	// Create command with context for cancellation
-	cmd := exec.CommandContext(ctx, "yt-dlp", "-x", "--audio-format", "mp3", "-o", outputTemplate, url)
-
-	discord.ChannelMessageSend(channelID, "⬇️ Downloading YouTube audio...")
+	cmd := exec.CommandContext(ctx, "yt-dlp", "-x", "--audio-format", "mp3", "--no-playlist", "-o", outputTemplate, url)
 
 	// Capture command output for debugging
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		if ctx.Err() != nil {
-			discord.ChannelMessageSend(channelID, "❌ YouTube download cancelled.")
-		} else {
-			discord.ChannelMessageSend(channelID, "❌ Failed to download audio.")
+		if ctx.Err() == nil {
 			log.Printf("yt-dlp error: %v\nOutput: %s", err, string(output))
 		}
-		return
+		return "", fmt.Errorf("failed to download audio")
 	}
 
 	// The actual filename will have .mp3 extension
@@ -83,28 +99,19 @@ func downloadAndPlayYT(ctx context.Context, discord *discordgo.Session, channelI
 		}
 
 		if !found {
-			discord.ChannelMessageSend(channelID, "❌ Downloaded file not found or conversion failed.")
 			log.Printf("Expected file %s not found", actualFileName)
-			return
+			return "", fmt.Errorf("downloaded file not found or conversion failed")
 		}
 	}
 
 	// Wait for file to be ready
 	if err := waitForfileReady(actualFileName, 5*time.Second); err != nil {
-		discord.ChannelMessageSend(channelID, "❌ File not ready: "+err.Error())
-		return
+		os.Remove(actualFileName)
+		return "", fmt.Errorf("file not ready: %w", err)
 	}
 
-	// Add temp file to tracking
 	addTempFile(guildID, actualFileName)
-
-	discord.ChannelMessageSend(channelID, "🎵 Playing downloaded YouTube audio...")
-	playMP3(session, actualFileName, discord, channelID)
-
-	// Cleanup after a delay to ensure playback completes, but also remove from tracking
-	time.AfterFunc(5*time.Minute, func() {
-		removeTempFile(guildID, actualFileName)
-	})
+	return actualFileName, nil
 }
 
 func synthesizeToMP3(ctx context.Context, text string, filename string) error {
@@ -159,43 +166,66 @@ func synthesizeToMP3(ctx context.Context, text string, filename string) error {
 		return fmt.Errorf("generated file is empty")
 	}
 
-	log.Printf("Successfully created TTS file: %s (size: %d bytes)", filename)
+	log.Printf("Successfully created TTS file: %s", filename)
 	return nil
 }
 
-func soundPlay(discord *discordgo.Session, message *discordgo.MessageCreate) {
-	mp3 := strings.TrimSpace(strings.TrimPrefix(message.Content, "!play "))
-	guildID := message.GuildID
+// Queue a user-supplied sound. Only soundboard names and files inside the
+// configured sound directories are accepted
+func soundPlay(cmd *CommandContext) {
+	name := cmd.String("sound")
+	guildID := cmd.GuildID
+
+	track, err := resolveSound(guildID, name)
+	if err != nil {
+		if errors.Is(err, errUnknownSound) {
+			cmd.Replyf("❌ Unknown sound **%s**. Try %ssb list", name, cmd.Prefix)
+		} else {
+			log.Printf("Rejected sound %q in guild %s: %v", name, guildID, err)
+			cmd.Reply("❌ Unknown sound. Use a soundboard name or a file from the sounds folder.")
+		}
+		return
+	}
 
-	botManager.mu.RLock()
-	session, ok := botManager.voiceConnections[guildID]
-	botManager.mu.RUnlock()
+	if !botConnect(cmd) {
+		return
+	}
 
-	if !ok || session == nil || session.connection == nil {
-		discord.ChannelMessageSend(message.ChannelID, "❌ Bot is not connected to a voice channel.")
+	session, ok := getVoiceSession(guildID)
+	if !ok {
+		cmd.Reply("❌ Bot is not connected to a voice channel.")
 		return
 	}
 
-	// Play in goroutine to avoid blocking
-	go playMP3(session, mp3, discord, message.ChannelID)
+	track.ChannelID = cmd.ChannelID
+	track.Requester = cmd.Author.ID
+	enqueueTrack(cmd.Discord, session, track)
 }
 
-func playMP3(session *VoiceSession, filename string, discord *discordgo.Session, channelID string) {
-	session.mu.Lock()
-	if session.isPlaying {
-		session.mu.Unlock()
-		return // Already playing something
+// Play one queued track, called by the queue worker
+func playTrack(ctx context.Context, session *VoiceSession, track *Track) {
+	if track.URL != "" || track.Requester != "" {
+		startNowPlaying(ctx, session, track)
 	}
-	session.isPlaying = true
-	session.mu.Unlock()
 
-	defer func() {
-		session.mu.Lock()
-		session.isPlaying = false
-		session.mu.Unlock()
-	}()
+	switch {
+	case track.Stream:
+		playYTStream(ctx, session, track)
+	case track.Filename == "" && track.URL != "":
+		// Playlist entries in download mode are fetched when they come up
+		playYTDownload(ctx, session, track)
+	default:
+		playMP3(ctx, session, track.Filename, session.discord, track.ChannelID)
+	}
+}
 
+// Stream a single file to the voice connection, called by the queue worker.
+// ctx is cancelled when the track is skipped or the session is torn down
+func playMP3(ctx context.Context, session *VoiceSession, filename string, discord *discordgo.Session, channelID string) {
+	session.mu.RLock()
 	vc := session.connection
+	session.mu.RUnlock()
+
 	if vc == nil {
 		return
 	}
@@ -208,87 +238,208 @@ func playMP3(session *VoiceSession, filename string, discord *discordgo.Session,
 	}
 
 	// Additional verification - try to open and check file integrity
-	if err := verifyAudioFile(filename); err != nil {
+	duration, err := verifyAudioFile(filename)
+	if err != nil {
 		discord.ChannelMessageSend(channelID, "❌ Audio file verification failed: "+err.Error())
 		log.Printf("File verification failed: %v", err)
 		return
 	}
+	session.setCurrentDuration(duration)
+
+	if err := playSource(ctx, session, vc, pcmSource{filename: filename}, discord, channelID); err != nil {
+		// Only this track ends, the worker moves on to the next one
+		discord.ChannelMessageSend(channelID, "❌ Playback failed: "+err.Error())
+		log.Printf("Playback of %s failed in guild %s: %v", filename, session.guildID, err)
+	}
+}
 
+// Where one ffmpeg pass reads its input from
+type pcmSource struct {
+	filename string       // local audio file
+	url      string       // piped from yt-dlp into ffmpeg's stdin when filename is empty
+	cache    *streamCache // optional copy of the streamed audio
+}
+
+// Copy of a streamed track written while it plays, so loops can replay it
+// from disk. Only a pass that starts at 0 and runs to the end fills it
+type streamCache struct {
+	filename string
+	complete bool
+}
+
+// Send a source to the voice connection. Each seek restarts ffmpeg at the
+// new offset on the same connection
+func playSource(ctx context.Context, session *VoiceSession, vc *discordgo.VoiceConnection, src pcmSource, discord *discordgo.Session, channelID string) error {
 	vc.Speaking(true)
 	defer vc.Speaking(false)
 
+	var offset time.Duration
+	for {
+		seekTo, seeking, err := streamPCM(ctx, session, vc, src, offset, discord, channelID)
+		if err != nil {
+			return err
+		}
+		if !seeking {
+			return nil
+		}
+		offset = seekTo
+	}
+}
+
+// Run one ffmpeg pass starting at offset and send its frames to the voice
+// connection. Returns the requested position when a seek interrupted it
+func streamPCM(ctx context.Context, session *VoiceSession, vc *discordgo.VoiceConnection, src pcmSource, offset time.Duration, discord *discordgo.Session, channelID string) (time.Duration, bool, error) {
+	settings := encoderSettings()
+
+	args := []string{}
+	if offset > 0 {
+		args = append(args, "-ss", fmt.Sprintf("%.3f", offset.Seconds()))
+	}
+	if src.filename != "" {
+		args = append(args, "-i", src.filename)
+	} else {
+		args = append(args, "-i", "pipe:0")
+	}
+	filters := session.trackFilters()
+	if len(filters) > 0 {
+		args = append(args, "-af", strings.Join(filters, ","))
+	}
+	// Each frame heard covers this much of the track, so the position and
+	// seeks stay in track time under tempo effects
+	step := time.Duration(float64(settings.frameDuration()) * filterTempo(filters))
+	args = append(args, "-f", "s16le", "-ar", "48000", "-ac", "2", "pipe:1")
+
 	// Create command with context for cancellation
-	cmd := exec.CommandContext(session.ctx, "ffmpeg", "-i", filename, "-f", "s16le", "-ar", "48000", "-ac", "2", "pipe:1")
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+
+	var upstream *ytStream
+	var cacheFile *os.File
+	if src.filename == "" {
+		stream, err := startYTStream(ctx, src.url)
+		if err != nil {
+			return 0, false, err
+		}
+		defer stream.close()
+		cmd.Stdin = stream.stdout
+		upstream = stream
+
+		if src.cache != nil && offset == 0 {
+			if cacheFile, err = os.Create(src.cache.filename); err != nil {
+				log.Printf("Failed to create stream cache %s: %v", src.cache.filename, err)
+			} else {
+				defer cacheFile.Close()
+				cmd.Stdin = io.TeeReader(stream.stdout, cacheFile)
+			}
+		}
+	}
+
 	stdout, err := cmd.StdoutPipe()
 
 	if err != nil {
 		discord.ChannelMessageSend(channelID, "❌ Failed to stream audio.")
 		log.Println("Failed to create ffmpeg pipe:", err)
-		return
+		return 0, false, nil
 	}
 
 	if err := cmd.Start(); err != nil {
 		discord.ChannelMessageSend(channelID, "❌ ffmpeg failed to start.")
 		log.Println("ffmpeg start failed:", err)
-		return
+		return 0, false, nil
 	}
 
+	// Stop yt-dlp first, Wait also waits for the goroutine copying into
+	// ffmpeg's stdin, which would otherwise block on the stream
+	waited := false
+	defer func() {
+		if upstream != nil {
+			upstream.close()
+		}
+		if !waited {
+			cmd.Wait()
+		}
+	}()
+
+	session.setPosition(offset)
+
+	var framesSent int
 	reader := bufio.NewReaderSize(stdout, 16384)
 	for {
-		// Check if context is cancelled
+		// Check if context is cancelled or a seek was requested
 		select {
-		case <-session.ctx.Done():
+		case <-ctx.Done():
+			cmd.Process.Kill()
+			return 0, false, nil
+		case to := <-session.seekTo:
 			cmd.Process.Kill()
-			return
+			return to, true, nil
 		default:
 		}
 
-		buf := make([]int16, 960*2)
+		// Hold the position while paused, a seek or skip still gets through
+		if to, seeking, ok := session.waitWhilePaused(ctx, vc); !ok {
+			cmd.Process.Kill()
+			return 0, false, nil
+		} else if seeking {
+			cmd.Process.Kill()
+			return to, true, nil
+		}
+
+		buf := make([]int16, settings.frameSamples())
 		err := binary.Read(reader, binary.LittleEndian, &buf)
 		if err != nil {
-			break
+			// A stream that ends before its first frame means yt-dlp failed
+			if upstream != nil && framesSent == 0 && ctx.Err() == nil {
+				return 0, false, upstream.failure()
+			}
+
+			// The cache is complete once ffmpeg consumed everything yt-dlp wrote
+			if cacheFile != nil && ctx.Err() == nil {
+				waited = true
+				src.cache.complete = cmd.Wait() == nil && upstream.wait() == nil
+			}
+			return 0, false, nil
 		}
+		applyVolume(buf, getAudioSettings(session.guildID).Volume)
 
-		select {
-		case vc.OpusSend <- pcmToOpus(buf):
-		case <-session.ctx.Done():
+		packet, err := session.pcmToOpus(buf, settings)
+		if err != nil {
 			cmd.Process.Kill()
-			return
+			return 0, false, err
 		}
-	}
-
-	cmd.Wait()
-}
 
-func pcmToOpus(pcm []int16) []byte {
-	if encoder == nil {
-		var err error
-		encoder, err = opus.NewEncoder(48000, 2, opus.AppAudio)
-		checkNilErr(err)
+		select {
+		case vc.OpusSend <- packet:
+			framesSent++
+			session.advancePosition(step)
+		case <-ctx.Done():
+			cmd.Process.Kill()
+			return 0, false, nil
+		}
 	}
-
-	opusBuf := make([]byte, 1000)
-	n, err := encoder.Encode(pcm, opusBuf)
-	checkNilErr(err)
-	return opusBuf[:n]
 }
 
-func verifyAudioFile(filename string) error {
+// Check the file with ffprobe and return its duration
+func verifyAudioFile(filename string) (time.Duration, error) {
 	// Quick ffprobe check to verify file integrity
 	cmd := exec.Command("ffprobe", "-v", "error", "-select_streams", "a:0",
 		"-show_entries", "stream=duration", "-of", "csv=p=0", filename)
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("ffprobe verification failed: %w (output: %s)", err, string(output))
+		return 0, fmt.Errorf("ffprobe verification failed: %w (output: %s)", err, string(output))
 	}
 
 	// If ffprobe can read the file and get duration, it's likely valid
 	outputStr := strings.TrimSpace(string(output))
 	if outputStr == "" || outputStr == "N/A" {
-		return fmt.Errorf("file appears to be invalid or corrupted")
+		return 0, fmt.Errorf("file appears to be invalid or corrupted")
+	}
+
+	seconds, err := strconv.ParseFloat(outputStr, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected duration %q from ffprobe", outputStr)
 	}
 
 	log.Printf("Audio file verified successfully (duration: %s)", outputStr)
-	return nil
+	return time.Duration(seconds * float64(time.Second)), nil
 }
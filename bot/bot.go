@@ -11,11 +11,11 @@ import (
 	"os/signal"
 	"strings"
 	"sync"
+	"time"
 )
 
 var BotToken string
 var voiceConnections = make(map[string]*discordgo.VoiceConnection)
-var encoder *opus.Encoder
 
 var tempFiles = make(map[string][]string) // guildID -> list of temp files
 var tempFilesMu sync.RWMutex
@@ -24,8 +24,6 @@ var activeOperations = make(map[string]*OperationContext)
 var operationsMu sync.RWMutex
 
 var configFilePath = "config.json"
-var sessions = make(map[string]*VoiceSession) // key: guildID
-var sessionsMu sync.Mutex
 
 // TrackedUsers represents the structure of our JSON file
 type TrackedUsers struct {
@@ -33,8 +31,132 @@ type TrackedUsers struct {
 }
 
 type BotConfig struct {
-	TrackedUsers         TrackedUsers      `json:"tracked_users"`
-	AnnouncementChannels map[string]string `json:"announcement_channels"` // guildID -> channelID
+	TrackedUsers         TrackedUsers             `json:"tracked_users"`
+	AnnouncementChannels map[string]string        `json:"announcement_channels"` // guildID -> channelID
+	Audio                map[string]AudioSettings `json:"audio"`                 // guildID -> playback settings
+	Encoder              EncoderSettings          `json:"encoder"`
+	YouTube              YouTubeSettings          `json:"youtube"`
+	EffectPresets        map[string]string        `json:"effect_presets"` // name -> ffmpeg -af filter graph, overrides built-ins
+	Soundboard           SoundboardSettings       `json:"soundboard"`
+	SlashCommands        SlashCommandSettings     `json:"slash_commands"`
+	Guilds               map[string]GuildSettings `json:"guilds"` // guildID -> command settings
+	Owners               []string                 `json:"owners"` // user IDs with the bot owner permission level
+	RateLimits           RateLimitSettings        `json:"rate_limits"`
+	Undo                 UndoSettings             `json:"undo"`
+	Roulette             RouletteSettings         `json:"roulette"`
+	Economy              EconomySettings          `json:"economy"`
+	Leaderboards         LeaderboardSettings      `json:"leaderboards"`
+	Casino               CasinoSettings           `json:"casino"`
+}
+
+// GuildSettings customizes how a guild talks to the bot
+type GuildSettings struct {
+	Prefix            string                  `json:"prefix,omitempty"`             // empty means the default "!"
+	MentionPrefix     bool                    `json:"mention_prefix,omitempty"`     // also accept @bot as a prefix
+	DisabledCommands  []string                `json:"disabled_commands,omitempty"`  // command names
+	Channels          map[string]ChannelRules `json:"channels,omitempty"`           // command name or "*" -> rules
+	CommandLevels     map[string]string       `json:"command_levels,omitempty"`     // command name -> required level
+	DJRoles           []string                `json:"dj_roles,omitempty"`           // role IDs granting DJ
+	ModRoles          []string                `json:"mod_roles,omitempty"`          // role IDs granting moderator
+	ProtectedUsers    []string                `json:"protected_users,omitempty"`    // user IDs voice-moving commands skip
+	ProtectedChannels []string                `json:"protected_channels,omitempty"` // voice channel IDs nobody is moved out of or into
+	RandomSeed        *int64                  `json:"random_seed,omitempty"`        // fixed seed for debugging, random outcomes repeat after restarts
+}
+
+// ChannelRules limits where a command may be used. A non-empty allow list
+// means only those channels, the deny list always wins
+type ChannelRules struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// RateLimitSettings tunes the per-user and per-guild command token buckets
+type RateLimitSettings struct {
+	Commands    map[string]RateLimit `json:"commands"`     // command name -> limits, replaces the built-in ones
+	BypassLevel string               `json:"bypass_level"` // lowest permission level that isn't limited, default "admin"
+	StateFile   string               `json:"state_file"`   // keep buckets here across restarts, empty for memory only
+}
+
+// RateLimit is the pair of buckets a command is limited by
+type RateLimit struct {
+	User  BucketLimit `json:"user"`  // each user in each guild
+	Guild BucketLimit `json:"guild"` // shared by everyone in a guild
+}
+
+// BucketLimit allows Burst uses back to back, then one more every
+// RefillSeconds. Zero values turn the bucket off
+type BucketLimit struct {
+	Burst         int     `json:"burst"`
+	RefillSeconds float64 `json:"refill_seconds"`
+}
+
+// CasinoSettings tunes the button-driven casino games
+type CasinoSettings struct {
+	TimeoutSeconds int `json:"timeout_seconds"` // idle time before a game settles itself, zero for the default in casino.go
+}
+
+// LeaderboardSettings sets when the weekly leaderboards start over
+type LeaderboardSettings struct {
+	ResetDay  string `json:"reset_day"`  // weekday name, default "monday"
+	ResetHour int    `json:"reset_hour"` // 0-23
+	Timezone  string `json:"timezone"`   // IANA name like "America/New_York", default UTC
+}
+
+// EconomySettings tunes the coin economy. Zero values fall back to the
+// defaults in economy.go
+type EconomySettings struct {
+	DailyAmount int64  `json:"daily_amount"` // coins !daily hands out
+	Currency    string `json:"currency"`     // what the coins are called
+}
+
+// RouletteSettings tunes !roulette. Zero values fall back to the defaults
+// in roulette.go
+type RouletteSettings struct {
+	Chambers     int    `json:"chambers"`      // chambers in the cylinder, one holds the round
+	Penalty      string `json:"penalty"`       // "move" (default) or "mute" for the loser
+	MuteSeconds  int    `json:"mute_seconds"`  // how long a "mute" penalty lasts
+	LobbySeconds int    `json:"lobby_seconds"` // how long the lobby stays open
+	TurnSeconds  int    `json:"turn_seconds"`  // how long a player may wait before the trigger pulls itself
+}
+
+// UndoSettings controls how long voice moves can be undone
+type UndoSettings struct {
+	WindowSeconds int `json:"window_seconds"` // zero falls back to the default in undo.go
+}
+
+// SlashCommandSettings controls how commands are registered with Discord
+type SlashCommandSettings struct {
+	Disabled bool     `json:"disabled"`  // keep to prefix commands only
+	GuildIDs []string `json:"guild_ids"` // sync to these guilds only, empty means globally
+}
+
+// SoundboardSettings locates the sound library and limits uploads.
+// Zero values fall back to the defaults in soundboard.go
+type SoundboardSettings struct {
+	Dir              string   `json:"dir"`                // directory holding index.json and the sound files
+	ExtraDirs        []string `json:"extra_dirs"`         // other directories !play may read files from
+	MaxLengthSeconds int      `json:"max_length_seconds"` // uploads are trimmed to this length
+	MaxUploadMB      int      `json:"max_upload_mb"`      // larger uploads are refused, default 8
+}
+
+// YouTubeSettings controls how !ytplay fetches audio
+type YouTubeSettings struct {
+	Mode string `json:"mode"` // "stream" (default) pipes yt-dlp into ffmpeg, "download" fetches the whole file first
+}
+
+// EncoderSettings configures the Opus encoder each voice session creates.
+// Zero values fall back to the defaults in encoder.go
+type EncoderSettings struct {
+	Bitrate     int    `json:"bitrate"`       // bits per second
+	FrameSizeMs int    `json:"frame_size_ms"` // must be 20 when set
+	Application string `json:"application"`   // "voip", "audio" or "lowdelay"
+}
+
+// AudioSettings holds the per-guild playback options
+type AudioSettings struct {
+	Volume    int    `json:"volume"`           // percent, 0-200
+	Normalize bool   `json:"normalize"`        // EBU R128 loudness normalization through ffmpeg
+	Effect    string `json:"effect,omitempty"` // effect preset name, empty for none
 }
 
 // Global variables to hold tracked users data
@@ -43,6 +165,7 @@ var (
 	trackingMutex sync.RWMutex
 	jsonFilePath  = "tracked_users.json"
 	botConfig     BotConfig // Assuming this is defined elsewhere
+	configMu      sync.RWMutex
 )
 
 type BotManager struct {
@@ -52,10 +175,29 @@ type BotManager struct {
 
 type VoiceSession struct {
 	connection *discordgo.VoiceConnection
+	discord    *discordgo.Session
+	guildID    string
 	ctx        context.Context
 	cancel     context.CancelFunc
 	isPlaying  bool
 	mu         sync.RWMutex
+
+	encoder *opus.Encoder // owned by the queue worker, never shared between guilds
+
+	queue   []*Track           // pending tracks, played in order by the worker
+	current *Track             // track the worker is playing right now
+	skip    context.CancelFunc // cancels only the current track
+	wake    chan struct{}      // signals the worker that the queue changed
+	loop    string             // loopOff, loopTrack or loopQueue
+	skipped bool               // current track was skipped, loopTrack moves on
+	stopped bool               // current track was stopped, no loop mode keeps it
+
+	paused   bool
+	resumeCh chan struct{}      // closed when a paused track resumes
+	seekTo   chan time.Duration // pending seek for the current track
+	position time.Duration      // playback position in the current track, in track time
+
+	nowPlaying *discordgo.Message // now-playing embed for the current track
 }
 
 type OperationContext struct {
@@ -168,6 +310,32 @@ func loadBotConfig() error {
 	if botConfig.AnnouncementChannels == nil {
 		botConfig.AnnouncementChannels = make(map[string]string)
 	}
+	if botConfig.Audio == nil {
+		botConfig.Audio = make(map[string]AudioSettings)
+	}
+	if botConfig.Guilds == nil {
+		botConfig.Guilds = make(map[string]GuildSettings)
+	}
+	if botConfig.Encoder, err = normalizeEncoderSettings(botConfig.Encoder); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Save the bot config, callers must hold configMu
+func saveBotConfig() error {
+	file, err := os.Create(configFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to create config file: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(&botConfig); err != nil {
+		return fmt.Errorf("failed to encode config JSON: %w", err)
+	}
 
 	return nil
 }
@@ -186,12 +354,40 @@ func Run() {
 		log.Fatalf("Failed to initialize voice tracking: %v", err)
 	}
 
+	if err := loadSoundboard(); err != nil {
+		log.Printf("Could not load soundboard index (starting with an empty library): %v", err)
+	}
+
+	if err := loadStats(); err != nil {
+		log.Printf("Could not load stats (starting fresh): %v", err)
+	}
+	if err := loadEconomy(); err != nil {
+		log.Printf("Could not load economy (starting fresh): %v", err)
+	}
+	if err := loadRouletteStats(); err != nil {
+		log.Printf("Could not load roulette stats (starting fresh): %v", err)
+	}
+	if err := loadRouletteMutes(); err != nil {
+		log.Printf("Could not load roulette mutes (starting fresh): %v", err)
+	}
+
+	if err := loadRateLimits(); err != nil {
+		log.Printf("Could not load rate limit state (starting fresh): %v", err)
+	}
+	stopBackground := make(chan struct{})
+	go runRateLimitSweeper(stopBackground)
+	go runStatsSaver(stopBackground)
+
 	// Register the voice state update handler - ADD THIS LINE
 	discord.AddHandler(onVoiceStateUpdate)
 	discord.AddHandler(newMessage)
+	discord.AddHandler(onInteractionCreate)
 
 	err = discord.Open()
 	checkNilErr(err)
+	resumeRouletteMutes(discord)
+
+	syncSlashCommands(discord)
 
 	fmt.Println("Bot running....")
 	c := make(chan os.Signal, 1)
@@ -200,5 +396,12 @@ func Run() {
 
 	// Cleanup all active operations before shutdown
 	killAllOperations()
+	close(stopBackground)
+	if err := saveRateLimits(); err != nil {
+		log.Printf("Failed to save rate limits: %v", err)
+	}
+	if err := saveStats(); err != nil {
+		log.Printf("Failed to save stats: %v", err)
+	}
 	discord.Close()
 }
@@ -0,0 +1,570 @@
+package bot
+
+import (
+	"discord-bot/bot/games"
+	"errors"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCasinoTimeout = 2 * time.Minute
+
+	casinoPrefix = "casino:"
+	casinoColor  = 0x2B8A3E
+
+	// What the bot says in voice after a game, shared with the slot machine
+	winAnnouncement  = "You Won You Lucky Fuck"
+	loseAnnouncement = "You A Fuckin Lose Dummy"
+)
+
+// Casino game kinds, also the middle part of their button IDs
+const (
+	gameBlackjack   = "bj"
+	gameHigherLower = "hl"
+	gameDice        = "dice"
+)
+
+// A game waiting on a player in a channel. Only one runs per channel, it
+// moves on through button clicks and ends on its own after a timeout
+type casinoGame struct {
+	id    int // tells the buttons of this game from older ones in the channel
+	kind  string
+	cmd   *CommandContext // the command that started it, used for TTS and timeouts
+	owner string          // user who started it
+	bet   int64           // stake per player
+	timer *time.Timer
+	rng   *Draws // logged when the game settles
+
+	blackjack   *games.Blackjack
+	higherLower *games.HigherLower
+	opponent    string // dice duel challengee
+}
+
+var (
+	casinoGames  = make(map[string]*casinoGame) // channelID -> game
+	nextCasinoID = 1
+	casinoMu     sync.Mutex
+)
+
+var gameNames = map[string]string{
+	gameBlackjack:   "blackjack",
+	gameHigherLower: "higher or lower",
+	gameDice:        "dice duel",
+}
+
+func casinoTimeout() time.Duration {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return secondsOr(botConfig.Casino.TimeoutSeconds, defaultCasinoTimeout)
+}
+
+// Take a stake from a wallet, a zero bet is a free game
+func takeBet(guildID, userID string, bet int64) error {
+	if bet <= 0 {
+		return nil
+	}
+	_, err := recordTransactions(guildID, Transaction{UserID: userID, Amount: -bet, Kind: txBet})
+	return err
+}
+
+// Take the caller's stake, replying when they can't cover it
+func placeBet(cmd *CommandContext, bet int64) bool {
+	if cmd.Has("bet") && bet <= 0 {
+		cmd.UsageError("bet must be positive")
+		return false
+	}
+
+	err := takeBet(cmd.GuildID, cmd.Author.ID, bet)
+	if errors.Is(err, errInsufficientFunds) {
+		cmd.Replyf("❌ You only have %s.", formatCoins(balance(cmd.GuildID, cmd.Author.ID)))
+		return false
+	}
+	if err != nil {
+		cmd.Reply("❌ " + err.Error())
+		return false
+	}
+	return true
+}
+
+// Give a stake back for a game that never happened
+func refundBet(guildID, userID string, bet int64) {
+	if bet <= 0 {
+		return
+	}
+	if _, err := recordTransactions(guildID, Transaction{UserID: userID, Amount: bet, Kind: txRefund}); err != nil {
+		log.Printf("Failed to refund %d to %s: %v", bet, userID, err)
+	}
+}
+
+// Pay out a finished game and count it for the gamble leaderboard. returned
+// is what the game gives back, stake included. The line describes the
+// wallet change, empty for free games
+func settleBet(guildID, userID string, bet, returned int64, won bool) string {
+	recordStat(guildID, userID, statGambleSpins, 1)
+	if won {
+		recordStat(guildID, userID, statGambleWins, 1)
+	}
+	if bet <= 0 {
+		return ""
+	}
+
+	if returned > 0 {
+		kind := txPayout
+		if returned == bet {
+			kind = txRefund
+		}
+		if _, err := recordTransactions(guildID, Transaction{UserID: userID, Amount: returned, Kind: kind}); err != nil {
+			log.Printf("Failed to pay out %d to %s: %v", returned, userID, err)
+		}
+	}
+
+	wallet := formatCoins(balance(guildID, userID))
+	switch {
+	case returned > bet:
+		return fmt.Sprintf("💰 <@%s> wins %s and has %s.", userID, formatCoins(returned), wallet)
+	case returned == bet:
+		return fmt.Sprintf("↔️ <@%s> gets the %s bet back and has %s.", userID, formatCoins(bet), wallet)
+	}
+	return fmt.Sprintf("💸 <@%s> lost %s and has %s.", userID, formatCoins(bet), wallet)
+}
+
+// Speak a game result in voice when the player is in a voice channel
+func announce(cmd *CommandContext, won bool) {
+	if vs, err := cmd.Discord.State.VoiceState(cmd.GuildID, cmd.Author.ID); err != nil || vs == nil || vs.ChannelID == "" {
+		return
+	}
+	if won {
+		sayHandler(cmd, winAnnouncement)
+	} else {
+		sayHandler(cmd, loseAnnouncement)
+	}
+}
+
+// Claim the channel for a game, or refund the stake and reply when another
+// game is running there
+func startCasinoGame(cmd *CommandContext, game *casinoGame) bool {
+	casinoMu.Lock()
+	if running := casinoGames[cmd.ChannelID]; running != nil {
+		kind := running.kind
+		casinoMu.Unlock()
+
+		refundBet(cmd.GuildID, cmd.Author.ID, game.bet)
+		cmd.Replyf("❌ A %s game is already running in this channel.", gameNames[kind])
+		return false
+	}
+
+	game.id = nextCasinoID
+	nextCasinoID++
+	casinoGames[cmd.ChannelID] = game
+	game.timer = time.AfterFunc(casinoTimeout(), func() { expireCasinoGame(cmd.ChannelID, game) })
+	casinoMu.Unlock()
+	return true
+}
+
+// Remove a finished game, callers must hold casinoMu
+func endCasinoGame(channelID string, game *casinoGame) {
+	game.timer.Stop()
+	if casinoGames[channelID] == game {
+		delete(casinoGames, channelID)
+	}
+}
+
+// Settle a game nobody finished: blackjack stands, higher or lower cashes
+// out and an unanswered duel is refunded
+func expireCasinoGame(channelID string, game *casinoGame) {
+	casinoMu.Lock()
+	if casinoGames[channelID] != game {
+		casinoMu.Unlock()
+		return
+	}
+	endCasinoGame(channelID, game)
+
+	var embed *discordgo.MessageEmbed
+	var won bool
+	switch game.kind {
+	case gameBlackjack:
+		game.blackjack.Stand()
+		embed, won = blackjackEmbed(game)
+		embed.Description = "⌛ Time's up, standing.\n" + embed.Description
+	case gameHigherLower:
+		embed, won = higherLowerCashOut(game)
+		embed.Description = "⌛ Time's up, cashing out.\n" + embed.Description
+	case gameDice:
+		refundBet(game.cmd.GuildID, game.owner, game.bet)
+		embed = &discordgo.MessageEmbed{Title: "🎲 Dice duel", Color: casinoColor,
+			Description: fmt.Sprintf("⌛ <@%s> didn't answer, the challenge is off.", game.opponent)}
+	}
+	casinoMu.Unlock()
+
+	if _, err := game.cmd.Discord.ChannelMessageSendEmbed(channelID, embed); err != nil {
+		log.Printf("Failed to send expired %s game: %v", gameNames[game.kind], err)
+	}
+	if game.kind != gameDice {
+		announce(game.cmd, won)
+	}
+}
+
+// A button for this game, its custom ID is casino:<kind>:<game id>:<action>
+func (g *casinoGame) button(action, label, emoji string, style discordgo.ButtonStyle) discordgo.Button {
+	return discordgo.Button{Label: label, Emoji: &discordgo.ComponentEmoji{Name: emoji}, Style: style,
+		CustomID: fmt.Sprintf("%s%s:%d:%s", casinoPrefix, g.kind, g.id, action)}
+}
+
+// Show a blackjack hand. A finished hand is settled here too, so call it
+// once per finished hand
+func blackjackEmbed(game *casinoGame) (*discordgo.MessageEmbed, bool) {
+	b := game.blackjack
+	dealer := games.Hand(b.Dealer) + fmt.Sprintf(" (%d)", games.HandValue(b.Dealer))
+	if b.State == games.BlackjackPlaying {
+		dealer = b.Dealer[0].String() + " 🂠"
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: "🃏 Blackjack",
+		Color: casinoColor,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Your hand", Value: fmt.Sprintf("%s (%d)", games.Hand(b.Player), games.HandValue(b.Player)), Inline: true},
+			{Name: "Dealer", Value: dealer, Inline: true},
+		},
+	}
+
+	var result string
+	switch b.State {
+	case games.BlackjackPlaying:
+		embed.Description = fmt.Sprintf("<@%s>, hit or stand?", game.owner)
+		return embed, false
+	case games.BlackjackNatural:
+		result = "🎉 Blackjack!"
+	case games.BlackjackWin:
+		result = "🏆 You beat the dealer."
+	case games.BlackjackPush:
+		result = "🤝 Push."
+	case games.BlackjackLoss:
+		result = "🏠 The house wins."
+		if len(b.Dealer) == 2 && games.HandValue(b.Dealer) == 21 {
+			result = "🏠 Dealer blackjack."
+		}
+	}
+
+	won := b.State == games.BlackjackNatural || b.State == games.BlackjackWin
+	embed.Description = result
+	game.rng.Log(fmt.Sprintf("%s bet %d, player %s (%d), dealer %s (%d)", game.owner, game.bet,
+		games.Hand(b.Player), games.HandValue(b.Player), games.Hand(b.Dealer), games.HandValue(b.Dealer)))
+	if line := settleBet(game.cmd.GuildID, game.owner, game.bet, b.Payout(game.bet), won); line != "" {
+		embed.Description += "\n" + line
+	}
+	return embed, won
+}
+
+func blackjackButtons(game *casinoGame) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			game.button("hit", "Hit", "➕", discordgo.PrimaryButton),
+			game.button("stand", "Stand", "✋", discordgo.SecondaryButton),
+		}},
+	}
+}
+
+// !blackjack [bet]
+func blackjackHandler(cmd *CommandContext) {
+	bet := int64(cmd.Int("bet"))
+	if !placeBet(cmd, bet) {
+		return
+	}
+
+	draws := newDraws(cmd.GuildID, "blackjack")
+	game := &casinoGame{kind: gameBlackjack, cmd: cmd, owner: cmd.Author.ID, bet: bet, rng: draws, blackjack: games.NewBlackjack(draws)}
+	if !startCasinoGame(cmd, game) {
+		return
+	}
+
+	if game.blackjack.State != games.BlackjackPlaying {
+		casinoMu.Lock()
+		endCasinoGame(cmd.ChannelID, game)
+		embed, won := blackjackEmbed(game)
+		casinoMu.Unlock()
+
+		cmd.ReplyEmbed(embed, nil)
+		announce(cmd, won)
+		return
+	}
+
+	embed, _ := blackjackEmbed(game)
+	cmd.ReplyEmbed(embed, blackjackButtons(game))
+}
+
+func higherLowerEmbed(game *casinoGame) *discordgo.MessageEmbed {
+	h := game.higherLower
+	return &discordgo.MessageEmbed{
+		Title: "🔼 Higher or Lower",
+		Color: casinoColor,
+		Description: fmt.Sprintf("Card: **%s**\nStreak: %d, cashing out returns %.2f× (%s).\n<@%s>, higher or lower?",
+			h.Current, h.Streak, h.Multiplier, formatCoins(h.Payout(game.bet)), game.owner),
+	}
+}
+
+// Settle a higher or lower game, callers must hold casinoMu
+func higherLowerCashOut(game *casinoGame) (*discordgo.MessageEmbed, bool) {
+	h := game.higherLower
+	won := !h.Lost && h.Multiplier > 1
+	game.rng.Log(fmt.Sprintf("%s bet %d, last card %s, streak %d, multiplier %.4f, lost %t", game.owner, game.bet, h.Current, h.Streak, h.Multiplier, h.Lost))
+
+	embed := &discordgo.MessageEmbed{Title: "🔼 Higher or Lower", Color: casinoColor}
+	if h.Lost {
+		embed.Description = fmt.Sprintf("Card: **%s**\n❌ Wrong after %d right guess(es).", h.Current, h.Streak)
+	} else {
+		embed.Description = fmt.Sprintf("Card: **%s**\n💼 Cashed out after %d right guess(es).", h.Current, h.Streak)
+	}
+	if line := settleBet(game.cmd.GuildID, game.owner, game.bet, h.Payout(game.bet), won); line != "" {
+		embed.Description += "\n" + line
+	}
+	return embed, won
+}
+
+func higherLowerButtons(game *casinoGame) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			game.button("higher", "Higher", "🔼", discordgo.PrimaryButton),
+			game.button("lower", "Lower", "🔽", discordgo.PrimaryButton),
+			game.button("cash", "Cash out", "💼", discordgo.SuccessButton),
+		}},
+	}
+}
+
+// !higherlower [bet]
+func higherLowerHandler(cmd *CommandContext) {
+	bet := int64(cmd.Int("bet"))
+	if !placeBet(cmd, bet) {
+		return
+	}
+
+	draws := newDraws(cmd.GuildID, "higherlower")
+	game := &casinoGame{kind: gameHigherLower, cmd: cmd, owner: cmd.Author.ID, bet: bet, rng: draws, higherLower: games.NewHigherLower(draws)}
+	if !startCasinoGame(cmd, game) {
+		return
+	}
+	cmd.ReplyEmbed(higherLowerEmbed(game), higherLowerButtons(game))
+}
+
+// !coinflip <heads|tails> [bet], settled on the spot
+func coinFlipHandler(cmd *CommandContext) {
+	bet := int64(cmd.Int("bet"))
+	if !placeBet(cmd, bet) {
+		return
+	}
+
+	draws := newDraws(cmd.GuildID, "coinflip")
+	side := games.FlipCoin(draws)
+	won := side == cmd.String("call")
+	draws.Log(fmt.Sprintf("%s bet %d on %s, landed %s", cmd.Author.ID, bet, cmd.String("call"), side))
+
+	var returned int64
+	response := fmt.Sprintf("🪙 It's **%s**! ", side)
+	if won {
+		returned = 2 * bet
+		response += "You called it."
+	} else {
+		response += "Better luck next time."
+	}
+	if line := settleBet(cmd.GuildID, cmd.Author.ID, bet, returned, won); line != "" {
+		response += "\n" + line
+	}
+
+	cmd.Reply(response)
+	announce(cmd, won)
+}
+
+// !dice @user [bet] challenges someone to a duel, both stake the bet and
+// the higher roll takes the pot
+func diceHandler(cmd *CommandContext) {
+	opponent := cmd.String("user")
+	if opponent == cmd.Author.ID {
+		cmd.Reply("❌ You can't duel yourself.")
+		return
+	}
+
+	bet := int64(cmd.Int("bet"))
+	if !placeBet(cmd, bet) {
+		return
+	}
+
+	game := &casinoGame{kind: gameDice, cmd: cmd, owner: cmd.Author.ID, bet: bet, opponent: opponent}
+	if !startCasinoGame(cmd, game) {
+		return
+	}
+
+	stake := "for fun"
+	if bet > 0 {
+		stake = "for " + formatCoins(bet) + " each"
+	}
+	cmd.ReplyEmbed(&discordgo.MessageEmbed{
+		Title:       "🎲 Dice duel",
+		Color:       casinoColor,
+		Description: fmt.Sprintf("<@%s> challenges <@%s> to a dice duel %s. Do you accept?", cmd.Author.ID, opponent, stake),
+	}, []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			game.button("accept", "Accept", "✅", discordgo.SuccessButton),
+			game.button("decline", "Decline", "❌", discordgo.DangerButton),
+		}},
+	})
+}
+
+// Accept or decline a duel, callers must hold casinoMu. Returns the
+// message to show, or an error for the clicking user only
+func answerDiceDuel(game *casinoGame, userID string, accept bool) (*discordgo.MessageEmbed, error) {
+	guildID := game.cmd.GuildID
+	embed := &discordgo.MessageEmbed{Title: "🎲 Dice duel", Color: casinoColor}
+
+	if !accept {
+		refundBet(guildID, game.owner, game.bet)
+		embed.Description = fmt.Sprintf("<@%s> called off the duel.", userID)
+		return embed, nil
+	}
+
+	if err := takeBet(guildID, game.opponent, game.bet); errors.Is(err, errInsufficientFunds) {
+		return nil, fmt.Errorf("you only have %s", formatCoins(balance(guildID, game.opponent)))
+	} else if err != nil {
+		return nil, err
+	}
+
+	draws := newDraws(guildID, "dice")
+	duel := games.RollDuel(draws)
+	draws.Log(fmt.Sprintf("%s rolled %v, %s rolled %v, bet %d", game.owner, duel.Challenger, game.opponent, duel.Opponent, game.bet))
+	var challengerBack, opponentBack int64
+	switch duel.Winner {
+	case 1:
+		challengerBack = 2 * game.bet
+	case 2:
+		opponentBack = 2 * game.bet
+	default:
+		challengerBack, opponentBack = game.bet, game.bet
+	}
+
+	lines := []string{
+		fmt.Sprintf("<@%s> rolls 🎲 %d + %d = **%d**", game.owner, duel.Challenger[0], duel.Challenger[1], duel.Challenger.Total()),
+		fmt.Sprintf("<@%s> rolls 🎲 %d + %d = **%d**", game.opponent, duel.Opponent[0], duel.Opponent[1], duel.Opponent.Total()),
+	}
+	switch duel.Winner {
+	case 1:
+		lines = append(lines, fmt.Sprintf("🏆 <@%s> wins!", game.owner))
+	case 2:
+		lines = append(lines, fmt.Sprintf("🏆 <@%s> wins!", game.opponent))
+	default:
+		lines = append(lines, "🤝 It's a tie.")
+	}
+	for _, line := range []string{
+		settleBet(guildID, game.owner, game.bet, challengerBack, duel.Winner == 1),
+		settleBet(guildID, game.opponent, game.bet, opponentBack, duel.Winner == 2),
+	} {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	embed.Description = strings.Join(lines, "\n")
+	return embed, nil
+}
+
+// Buttons on blackjack, higher or lower and dice duel messages. Custom IDs
+// are casino:<kind>:<game id>:<action>, buttons of a game that already
+// ended don't touch the one running in the channel now
+func handleCasinoButton(discord *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	kind, rest, _ := strings.Cut(strings.TrimPrefix(interaction.MessageComponentData().CustomID, casinoPrefix), ":")
+	idText, action, _ := strings.Cut(rest, ":")
+	id, _ := strconv.Atoi(idText)
+	user := interaction.User
+	if interaction.Member != nil {
+		user = interaction.Member.User
+	}
+
+	casinoMu.Lock()
+	game := casinoGames[interaction.ChannelID]
+	if game == nil || game.kind != kind || game.id != id {
+		casinoMu.Unlock()
+		respondEphemeral(discord, interaction, "❌ That game is over.")
+		return
+	}
+
+	player := game.owner
+	if kind == gameDice && action == "accept" {
+		player = game.opponent
+	}
+	if user.ID != player && !(kind == gameDice && action == "decline" && user.ID == game.opponent) {
+		casinoMu.Unlock()
+		respondEphemeral(discord, interaction, "❌ This isn't your game.")
+		return
+	}
+
+	var embed *discordgo.MessageEmbed
+	components := []discordgo.MessageComponent{}
+	finished, won := false, false
+
+	switch kind + ":" + action {
+	case gameBlackjack + ":hit", gameBlackjack + ":stand":
+		if action == "hit" {
+			game.blackjack.Hit()
+		} else {
+			game.blackjack.Stand()
+		}
+		finished = game.blackjack.State != games.BlackjackPlaying
+		embed, won = blackjackEmbed(game)
+		if !finished {
+			components = blackjackButtons(game)
+		}
+
+	case gameHigherLower + ":higher", gameHigherLower + ":lower":
+		if _, right := game.higherLower.Guess(action == "higher"); right {
+			embed, components = higherLowerEmbed(game), higherLowerButtons(game)
+		} else {
+			finished = true
+			embed, won = higherLowerCashOut(game)
+		}
+
+	case gameHigherLower + ":cash":
+		finished = true
+		embed, won = higherLowerCashOut(game)
+
+	case gameDice + ":accept", gameDice + ":decline":
+		var err error
+		embed, err = answerDiceDuel(game, user.ID, action == "accept")
+		if err != nil {
+			casinoMu.Unlock()
+			respondEphemeral(discord, interaction, "❌ "+capitalize(err.Error())+".")
+			return
+		}
+		endCasinoGame(interaction.ChannelID, game)
+
+	default:
+		casinoMu.Unlock()
+		respondEphemeral(discord, interaction, "❌ Unknown button.")
+		return
+	}
+
+	if finished {
+		endCasinoGame(interaction.ChannelID, game)
+	} else if kind != gameDice {
+		game.timer.Reset(casinoTimeout())
+	}
+	casinoMu.Unlock()
+
+	err := discord.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: components,
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to update %s game: %v", gameNames[kind], err)
+	}
+
+	if finished {
+		announce(game.cmd, won)
+	}
+}
@@ -0,0 +1,430 @@
+package bot
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const defaultPrefix = "!"
+
+// ArgType decides how a command argument is parsed
+type ArgType int
+
+const (
+	ArgString     ArgType = iota // a single word
+	ArgInt                       // a whole number
+	ArgDuration                  // a timestamp like 1:23 or 1:02:03
+	ArgUser                      // a user mention or ID, stored as the ID
+	ArgChannel                   // a channel mention or ID, stored as the ID
+	ArgText                      // everything left on the line, must be the last text argument
+	ArgAttachment                // a file attached to the message, takes no text
+)
+
+// Arg describes one positional argument of a command
+type Arg struct {
+	Name        string
+	Type        ArgType
+	Optional    bool
+	Choices     []string // allowed values for ArgString, matched case-insensitively
+	Description string   // shown for slash command options, defaults to the name
+}
+
+// Command is one entry in the command registry
+type Command struct {
+	Name        string
+	Aliases     []string
+	Category    string
+	Description string
+	Emoji       string
+	Args        []Arg
+	ArgHelp     string          // hand-written argument summary when Args can't express it
+	Examples    []string        // argument strings shown by !help <command>
+	RateLimit   RateLimit       // token buckets limiting use, the config can override them
+	Level       PermissionLevel // who may run it, guilds can override this
+	GuildOnly   bool            // reject the command in DMs
+	NeedsVoice  bool            // the caller must be in a voice channel
+	Run         func(cmd *CommandContext)
+}
+
+// CommandContext is what a command handler gets to work with
+type CommandContext struct {
+	Discord *discordgo.Session
+	Message *discordgo.MessageCreate
+	Command *Command
+
+	GuildID   string
+	ChannelID string
+	Author    *discordgo.User
+
+	// Set when the command came in as a slash command
+	Interaction *discordgo.InteractionCreate
+
+	// Prefix and name the command was invoked with, the name may be an alias
+	Prefix  string
+	Invoked string
+	args    map[string]any
+
+	mu        sync.Mutex
+	responded bool // whether the deferred interaction response has been filled in
+}
+
+var (
+	commands    = make(map[string]*Command) // names and aliases
+	commandList []*Command                  // registration order
+)
+
+// Add a command to the registry, duplicate names are a programming error
+func registerCommand(command *Command) {
+	for _, name := range append([]string{command.Name}, command.Aliases...) {
+		if _, exists := commands[name]; exists {
+			panic("duplicate command name: " + name)
+		}
+		commands[name] = command
+	}
+	commandList = append(commandList, command)
+}
+
+func lookupCommand(name string) (*Command, bool) {
+	command, ok := commands[strings.ToLower(name)]
+	return command, ok
+}
+
+// Usage line like "!seek <position>" or "!loop [track|queue|off]"
+func (c *Command) Usage(prefix string) string {
+	usage := prefix + c.Name
+	if c.ArgHelp != "" {
+		return usage + " " + c.ArgHelp
+	}
+	for _, arg := range c.Args {
+		label := arg.Name
+		if len(arg.Choices) > 0 {
+			label = strings.Join(arg.Choices, "|")
+		} else if arg.Type == ArgText {
+			label += "..."
+		} else if arg.Type == ArgAttachment {
+			label += " attachment"
+		}
+
+		if arg.Optional {
+			usage += " [" + label + "]"
+		} else {
+			usage += " <" + label + ">"
+		}
+	}
+	return usage
+}
+
+// Split off the first whitespace separated word
+func nextWord(input string) (string, string) {
+	input = strings.TrimLeftFunc(input, unicode.IsSpace)
+	end := strings.IndexFunc(input, unicode.IsSpace)
+	if end < 0 {
+		return input, ""
+	}
+	return input[:end], input[end:]
+}
+
+// Strip a mention wrapper like <@123>, <@!123> or <#123> down to the ID
+func mentionID(word string, prefixes ...string) (string, bool) {
+	if strings.HasPrefix(word, "<") && strings.HasSuffix(word, ">") {
+		word = strings.TrimSuffix(strings.TrimPrefix(word, "<"), ">")
+		trimmed := false
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(word, prefix) {
+				word, trimmed = strings.TrimPrefix(word, prefix), true
+				break
+			}
+		}
+		if !trimmed {
+			return "", false
+		}
+	}
+
+	if _, err := strconv.ParseUint(word, 10, 64); err != nil {
+		return "", false
+	}
+	return word, true
+}
+
+// Parse the raw text after the command name against the argument schema.
+// Attachment arguments take the message's attachments in order
+func (c *Command) parseArgs(input string, attachments []*discordgo.MessageAttachment) (map[string]any, error) {
+	values := make(map[string]any)
+
+	for _, arg := range c.Args {
+		if arg.Type == ArgAttachment {
+			if len(attachments) == 0 {
+				if arg.Optional {
+					continue
+				}
+				return nil, fmt.Errorf("missing %s attachment", arg.Name)
+			}
+			values[arg.Name] = attachments[0]
+			attachments = attachments[1:]
+			continue
+		}
+
+		if arg.Type == ArgText {
+			text := strings.TrimSpace(input)
+			input = ""
+			if text == "" {
+				if arg.Optional {
+					continue
+				}
+				return nil, fmt.Errorf("missing %s", arg.Name)
+			}
+			values[arg.Name] = text
+			continue
+		}
+
+		var word string
+		word, input = nextWord(input)
+		if word == "" {
+			if arg.Optional {
+				continue
+			}
+			return nil, fmt.Errorf("missing %s", arg.Name)
+		}
+
+		switch arg.Type {
+		case ArgInt:
+			n, err := strconv.Atoi(word)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be a number", arg.Name)
+			}
+			values[arg.Name] = n
+
+		case ArgDuration:
+			d, err := parseTimestamp(word)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be a timestamp like 1:23", arg.Name)
+			}
+			values[arg.Name] = d
+
+		case ArgUser:
+			id, ok := mentionID(word, "@!", "@")
+			if !ok {
+				return nil, fmt.Errorf("%s must be a user mention", arg.Name)
+			}
+			values[arg.Name] = id
+
+		case ArgChannel:
+			id, ok := mentionID(word, "#")
+			if !ok {
+				return nil, fmt.Errorf("%s must be a channel mention", arg.Name)
+			}
+			values[arg.Name] = id
+
+		default:
+			if len(arg.Choices) > 0 {
+				matched := false
+				for _, choice := range arg.Choices {
+					if strings.EqualFold(word, choice) {
+						word, matched = choice, true
+						break
+					}
+				}
+				if !matched {
+					return nil, fmt.Errorf("%s must be one of %s", arg.Name, strings.Join(arg.Choices, ", "))
+				}
+			}
+			values[arg.Name] = word
+		}
+	}
+
+	if extra := strings.TrimSpace(input); extra != "" {
+		return nil, fmt.Errorf("unexpected %q", extra)
+	}
+	return values, nil
+}
+
+// Find the command a message invokes. Only messages that start with one of
+// the prefixes immediately followed by a registered name or alias match
+func parseCommand(content string, prefixes []string) (*Command, string, string, string, bool) {
+	content = strings.TrimSpace(content)
+
+	for _, prefix := range prefixes {
+		if !strings.HasPrefix(content, prefix) {
+			continue
+		}
+
+		name, rest := nextWord(strings.TrimPrefix(content, prefix))
+		if command, ok := lookupCommand(name); ok {
+			return command, prefix, strings.ToLower(name), rest, true
+		}
+	}
+	return nil, "", "", "", false
+}
+
+// Run the command a message invokes, if any
+func dispatchCommand(discord *discordgo.Session, message *discordgo.MessageCreate) {
+	command, prefix, invoked, rest, ok := parseCommand(message.Content, commandPrefixes(discord, message.GuildID))
+	if !ok {
+		return
+	}
+
+	// Usage lines read better with the text prefix than with a mention
+	if strings.HasPrefix(prefix, "<@") {
+		prefix = guildPrefix(message.GuildID)
+	}
+
+	cmd := &CommandContext{
+		Discord:   discord,
+		Message:   message,
+		Command:   command,
+		GuildID:   message.GuildID,
+		ChannelID: message.ChannelID,
+		Author:    message.Author,
+		Prefix:    prefix,
+		Invoked:   invoked,
+	}
+
+	if !commandAllowedHere(cmd) {
+		return
+	}
+
+	args, err := command.parseArgs(rest, message.Attachments)
+	if err != nil {
+		cmd.UsageError(err.Error())
+		return
+	}
+	cmd.args = args
+
+	executeCommand(cmd)
+}
+
+// Whether the command can be used where it was asked for, replying when it
+// can't. Runs before the arguments are parsed so a disabled command never
+// answers with its usage
+func commandAllowedHere(cmd *CommandContext) bool {
+	command := cmd.Command
+
+	if !command.availableIn(cmd.GuildID) {
+		cmd.Reply("❌ This command only works in a server.")
+		return false
+	}
+
+	if reason := commandBlocked(command, cmd.GuildID, cmd.ChannelID); reason != "" {
+		cmd.Replyf("🚫 %s%s %s.", cmd.Prefix, command.Name, reason)
+		return false
+	}
+	return true
+}
+
+// Checks shared by prefix and slash commands once commandAllowedHere passed
+// and the arguments are parsed, then the command itself
+func executeCommand(cmd *CommandContext) {
+	command := cmd.Command
+
+	if !checkPermission(cmd) {
+		cmd.Replyf("🔒 %s%s needs the **%s** permission level.", cmd.Prefix, command.Name, commandLevel(command, cmd.GuildID))
+		return
+	}
+
+	if command.NeedsVoice {
+		voiceState, err := cmd.Discord.State.VoiceState(cmd.GuildID, cmd.Author.ID)
+		if err != nil || voiceState == nil || voiceState.ChannelID == "" {
+			cmd.Reply("❌ You must be in a voice channel to use this command.")
+			return
+		}
+	}
+
+	if !checkRateLimit(cmd) {
+		return
+	}
+
+	log.Printf("Command %s from %s in guild %s", command.Name, cmd.Author.ID, cmd.GuildID)
+	recordStat(cmd.GuildID, cmd.Author.ID, statCommands, 1)
+	command.Run(cmd)
+}
+
+// Whether a command can be used where it was asked for, DMs hide guild-only ones
+func (c *Command) availableIn(guildID string) bool {
+	return guildID != "" || !c.GuildOnly
+}
+
+// Send a reply to wherever the command came from
+func (cmd *CommandContext) send(msg *discordgo.MessageSend) {
+	var err error
+	if cmd.Interaction != nil {
+		err = cmd.sendInteraction(msg)
+	} else {
+		_, err = cmd.Discord.ChannelMessageSendComplex(cmd.ChannelID, msg)
+	}
+	if err != nil {
+		log.Printf("Failed to reply to %s: %v", cmd.Command.Name, err)
+	}
+}
+
+func (cmd *CommandContext) Reply(content string) {
+	cmd.send(&discordgo.MessageSend{Content: content})
+}
+
+func (cmd *CommandContext) Replyf(format string, args ...any) {
+	cmd.Reply(fmt.Sprintf(format, args...))
+}
+
+// Send an embed, with optional buttons
+func (cmd *CommandContext) ReplyEmbed(embed *discordgo.MessageEmbed, components []discordgo.MessageComponent) {
+	cmd.send(&discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{embed},
+		Components: components,
+	})
+}
+
+// Send a file
+func (cmd *CommandContext) ReplyFile(name string, file io.Reader) {
+	cmd.send(&discordgo.MessageSend{
+		Files: []*discordgo.File{{Name: name, Reader: file}},
+	})
+}
+
+// Reply with the reason and the command's usage line
+func (cmd *CommandContext) UsageError(reason string) {
+	if reason == "" {
+		cmd.Replyf("❌ Usage: `%s`", cmd.Command.Usage(cmd.Prefix))
+		return
+	}
+	cmd.Replyf("❌ %s. Usage: `%s`", capitalize(reason), cmd.Command.Usage(cmd.Prefix))
+}
+
+// Whether an optional argument was given
+func (cmd *CommandContext) Has(name string) bool {
+	_, ok := cmd.args[name]
+	return ok
+}
+
+func (cmd *CommandContext) String(name string) string {
+	value, _ := cmd.args[name].(string)
+	return value
+}
+
+func (cmd *CommandContext) Int(name string) int {
+	value, _ := cmd.args[name].(int)
+	return value
+}
+
+func (cmd *CommandContext) Duration(name string) time.Duration {
+	value, _ := cmd.args[name].(time.Duration)
+	return value
+}
+
+// The named attachment argument, nil when it wasn't given
+func (cmd *CommandContext) Attachment(name string) *discordgo.MessageAttachment {
+	value, _ := cmd.args[name].(*discordgo.MessageAttachment)
+	return value
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
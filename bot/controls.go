@@ -0,0 +1,147 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"time"
+)
+
+func (vs *VoiceSession) setPosition(position time.Duration) {
+	vs.mu.Lock()
+	vs.position = position
+	vs.mu.Unlock()
+}
+
+func (vs *VoiceSession) advancePosition(step time.Duration) {
+	vs.mu.Lock()
+	vs.position += step
+	vs.mu.Unlock()
+}
+
+func (vs *VoiceSession) currentPosition() time.Duration {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	return vs.position
+}
+
+// Block the stream loop while the session is paused. Returns ok=false when
+// the track was cancelled, or seeking=true when a seek arrived during the pause
+func (vs *VoiceSession) waitWhilePaused(ctx context.Context, vc *discordgo.VoiceConnection) (to time.Duration, seeking bool, ok bool) {
+	vs.mu.RLock()
+	paused := vs.paused
+	resume := vs.resumeCh
+	vs.mu.RUnlock()
+
+	if !paused {
+		return 0, false, true
+	}
+
+	vc.Speaking(false)
+	defer vc.Speaking(true)
+
+	select {
+	case <-resume:
+		return 0, false, true
+	case to := <-vs.seekTo:
+		return to, true, true
+	case <-ctx.Done():
+		return 0, false, false
+	}
+}
+
+// Pause the current track, keeping its position
+func (vs *VoiceSession) pause() error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	if vs.current == nil {
+		return fmt.Errorf("nothing is playing right now")
+	}
+	if vs.paused {
+		return fmt.Errorf("playback is already paused")
+	}
+	vs.paused = true
+	vs.resumeCh = make(chan struct{})
+	return nil
+}
+
+func (vs *VoiceSession) resume() error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	if vs.current == nil {
+		return fmt.Errorf("nothing is playing right now")
+	}
+	if !vs.paused {
+		return fmt.Errorf("playback is not paused")
+	}
+	vs.paused = false
+	close(vs.resumeCh)
+	return nil
+}
+
+// Ask the stream loop to restart the current track at the given offset
+func (vs *VoiceSession) seek(to time.Duration) error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	if vs.current == nil {
+		return fmt.Errorf("nothing is playing right now")
+	}
+
+	// Replace any seek the loop hasn't picked up yet. Holding vs.mu keeps
+	// concurrent seeks from both draining first, so the send never blocks
+	select {
+	case <-vs.seekTo:
+	default:
+	}
+	select {
+	case vs.seekTo <- to:
+	default:
+	}
+	return nil
+}
+
+func pauseHandler(cmd *CommandContext) {
+	session, ok := getVoiceSession(cmd.GuildID)
+	if !ok {
+		cmd.Reply("❌ Bot is not connected to a voice channel.")
+		return
+	}
+
+	if err := session.pause(); err != nil {
+		cmd.Reply("❌ " + err.Error())
+		return
+	}
+	cmd.Replyf("⏸️ Paused at `%s`.", formatTimestamp(session.currentPosition()))
+}
+
+func resumeHandler(cmd *CommandContext) {
+	session, ok := getVoiceSession(cmd.GuildID)
+	if !ok {
+		cmd.Reply("❌ Bot is not connected to a voice channel.")
+		return
+	}
+
+	if err := session.resume(); err != nil {
+		cmd.Reply("❌ " + err.Error())
+		return
+	}
+	cmd.Reply("▶️ Resumed.")
+}
+
+func seekHandler(cmd *CommandContext) {
+	session, ok := getVoiceSession(cmd.GuildID)
+	if !ok {
+		cmd.Reply("❌ Bot is not connected to a voice channel.")
+		return
+	}
+
+	to := cmd.Duration("position")
+	if err := session.seek(to); err != nil {
+		cmd.Reply("❌ " + err.Error())
+		return
+	}
+	cmd.Replyf("⏩ Seeking to `%s`.", formatTimestamp(to))
+}
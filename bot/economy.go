@@ -0,0 +1,359 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for zero values in EconomySettings
+const (
+	defaultDailyAmount = 100
+	defaultCurrency    = "coins"
+
+	dailyInterval = 20 * time.Hour // a little under a day so the time of day can drift
+	ledgerPage    = 10
+)
+
+// Ledger entry kinds
+const (
+	txDaily   = "daily"
+	txBet     = "bet"
+	txPayout  = "payout"
+	txGive    = "give"
+	txReceive = "receive"
+	txRefund  = "refund"
+	txReverse = "reverse"
+)
+
+var economyPath = "economy.json"
+
+// Transaction is one change to one wallet. Every balance change is recorded
+// as one, so the ledger can explain and undo any balance
+type Transaction struct {
+	ID       int       `json:"id"`
+	Time     time.Time `json:"time"`
+	UserID   string    `json:"user_id"`
+	Amount   int64     `json:"amount"` // positive credits, negative debits
+	Kind     string    `json:"kind"`
+	Ref      int       `json:"ref,omitempty"` // the other half of a transfer, or the entry a reversal undoes
+	Reversed bool      `json:"reversed,omitempty"`
+}
+
+// GuildEconomy is one guild's wallets and their history
+type GuildEconomy struct {
+	Balances  map[string]int64     `json:"balances"`   // userID -> balance
+	LastDaily map[string]time.Time `json:"last_daily"` // userID -> last !daily claim
+	Ledger    []Transaction        `json:"ledger"`
+	NextID    int                  `json:"next_id"`
+}
+
+var (
+	economy   = make(map[string]*GuildEconomy) // guildID -> economy
+	economyMu sync.Mutex
+)
+
+var errInsufficientFunds = errors.New("insufficient funds")
+
+func economySettings() EconomySettings {
+	configMu.RLock()
+	settings := botConfig.Economy
+	configMu.RUnlock()
+
+	if settings.DailyAmount <= 0 {
+		settings.DailyAmount = defaultDailyAmount
+	}
+	if settings.Currency == "" {
+		settings.Currency = defaultCurrency
+	}
+	return settings
+}
+
+func formatCoins(amount int64) string {
+	return fmt.Sprintf("%d %s", amount, economySettings().Currency)
+}
+
+func loadEconomy() error {
+	file, err := os.Open(economyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open economy: %w", err)
+	}
+	defer file.Close()
+
+	guilds := make(map[string]*GuildEconomy)
+	if err := json.NewDecoder(file).Decode(&guilds); err != nil {
+		return fmt.Errorf("failed to decode economy: %w", err)
+	}
+
+	economyMu.Lock()
+	economy = guilds
+	economyMu.Unlock()
+	return nil
+}
+
+// Save every guild's economy, callers must hold economyMu
+func saveEconomy() error {
+	file, err := os.Create(economyPath)
+	if err != nil {
+		return fmt.Errorf("failed to create economy: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(economy); err != nil {
+		return fmt.Errorf("failed to encode economy: %w", err)
+	}
+	return nil
+}
+
+// A guild's economy, created on first use. Callers must hold economyMu
+func guildEconomy(guildID string) *GuildEconomy {
+	e := economy[guildID]
+	if e == nil {
+		e = &GuildEconomy{NextID: 1}
+		economy[guildID] = e
+	}
+	if e.Balances == nil {
+		e.Balances = make(map[string]int64)
+	}
+	if e.LastDaily == nil {
+		e.LastDaily = make(map[string]time.Time)
+	}
+	return e
+}
+
+// Apply a set of transactions together, or none of them when a debit would
+// leave a wallet negative. Returns the recorded entries with their IDs.
+// Callers must hold economyMu
+func (e *GuildEconomy) apply(txs ...Transaction) ([]Transaction, error) {
+	after := make(map[string]int64)
+	for _, tx := range txs {
+		if _, ok := after[tx.UserID]; !ok {
+			after[tx.UserID] = e.Balances[tx.UserID]
+		}
+		after[tx.UserID] += tx.Amount
+		if tx.Amount < 0 && tx.Kind != txReverse && after[tx.UserID] < 0 {
+			return nil, errInsufficientFunds
+		}
+	}
+
+	now := time.Now()
+	first := e.NextID
+	for i := range txs {
+		txs[i].ID = e.NextID
+		txs[i].Time = now
+		e.NextID++
+	}
+	// Transfers point at each other
+	if len(txs) == 2 && txs[0].Kind == txGive {
+		txs[0].Ref, txs[1].Ref = first+1, first
+	}
+
+	for _, tx := range txs {
+		e.Balances[tx.UserID] += tx.Amount
+		e.Ledger = append(e.Ledger, tx)
+	}
+	return txs, nil
+}
+
+// Record transactions in a guild and persist them
+func recordTransactions(guildID string, txs ...Transaction) ([]Transaction, error) {
+	economyMu.Lock()
+	defer economyMu.Unlock()
+
+	recorded, err := guildEconomy(guildID).apply(txs...)
+	if err != nil {
+		return nil, err
+	}
+	if err := saveEconomy(); err != nil {
+		log.Printf("Failed to save economy: %v", err)
+	}
+	return recorded, nil
+}
+
+func balance(guildID, userID string) int64 {
+	economyMu.Lock()
+	defer economyMu.Unlock()
+	return guildEconomy(guildID).Balances[userID]
+}
+
+func dailyHandler(cmd *CommandContext) {
+	amount := economySettings().DailyAmount
+
+	economyMu.Lock()
+	defer economyMu.Unlock()
+
+	e := guildEconomy(cmd.GuildID)
+	if wait := dailyInterval - time.Since(e.LastDaily[cmd.Author.ID]); wait > 0 {
+		cmd.Replyf("⏳ You already claimed today, come back in %s.", wait.Round(time.Minute))
+		return
+	}
+
+	if _, err := e.apply(Transaction{UserID: cmd.Author.ID, Amount: amount, Kind: txDaily}); err != nil {
+		cmd.Reply("❌ " + err.Error())
+		return
+	}
+	e.LastDaily[cmd.Author.ID] = time.Now()
+	if err := saveEconomy(); err != nil {
+		log.Printf("Failed to save economy: %v", err)
+	}
+
+	cmd.Replyf("💰 Claimed %s, you now have %s.", formatCoins(amount), formatCoins(e.Balances[cmd.Author.ID]))
+}
+
+func balanceHandler(cmd *CommandContext) {
+	userID := cmd.Author.ID
+	if cmd.Has("user") {
+		userID = cmd.String("user")
+	}
+	cmd.Replyf("👛 <@%s> has %s.", userID, formatCoins(balance(cmd.GuildID, userID)))
+}
+
+func giveHandler(cmd *CommandContext) {
+	to := cmd.String("user")
+	amount := int64(cmd.Int("amount"))
+
+	switch {
+	case amount <= 0:
+		cmd.UsageError("amount must be positive")
+		return
+	case to == cmd.Author.ID:
+		cmd.Reply("❌ You can't pay yourself.")
+		return
+	}
+
+	_, err := recordTransactions(cmd.GuildID,
+		Transaction{UserID: cmd.Author.ID, Amount: -amount, Kind: txGive},
+		Transaction{UserID: to, Amount: amount, Kind: txReceive},
+	)
+	if errors.Is(err, errInsufficientFunds) {
+		cmd.Replyf("❌ You only have %s.", formatCoins(balance(cmd.GuildID, cmd.Author.ID)))
+		return
+	}
+	if err != nil {
+		cmd.Reply("❌ " + err.Error())
+		return
+	}
+	cmd.Replyf("🤝 <@%s> gave <@%s> %s.", cmd.Author.ID, to, formatCoins(amount))
+}
+
+func (tx Transaction) String() string {
+	line := fmt.Sprintf("`#%d` %s <@%s> %+d %s", tx.ID, tx.Time.Format("Jan 2 15:04"), tx.UserID, tx.Amount, tx.Kind)
+	if tx.Ref != 0 {
+		line += fmt.Sprintf(" (#%d)", tx.Ref)
+	}
+	if tx.Reversed {
+		line += " ~~reversed~~"
+	}
+	return line
+}
+
+// !ledger [@user] shows recent transactions, !ledger reverse <id> undoes one
+// and the other half of a transfer with it
+func ledgerHandler(cmd *CommandContext) {
+	args := strings.Fields(cmd.String("args"))
+
+	if len(args) == 2 && strings.EqualFold(args[0], "reverse") {
+		id, err := strconv.Atoi(strings.TrimPrefix(args[1], "#"))
+		if err != nil {
+			cmd.UsageError("reverse needs a transaction number")
+			return
+		}
+		ledgerReverse(cmd, id)
+		return
+	}
+
+	var userID string
+	if len(args) == 1 {
+		id, ok := mentionID(args[0], "@!", "@")
+		if !ok {
+			cmd.UsageError(args[0] + " isn't a user")
+			return
+		}
+		userID = id
+	} else if len(args) > 1 {
+		cmd.UsageError("give a user, or reverse and a transaction number")
+		return
+	}
+
+	economyMu.Lock()
+	ledger := guildEconomy(cmd.GuildID).Ledger
+	var lines []string
+	for i := len(ledger) - 1; i >= 0 && len(lines) < ledgerPage; i-- {
+		if userID == "" || ledger[i].UserID == userID {
+			lines = append(lines, ledger[i].String())
+		}
+	}
+	economyMu.Unlock()
+
+	if len(lines) == 0 {
+		cmd.Reply("📒 No transactions yet.")
+		return
+	}
+	cmd.Reply("📒 **Recent transactions**\n" + strings.Join(lines, "\n"))
+}
+
+func ledgerReverse(cmd *CommandContext, id int) {
+	economyMu.Lock()
+	defer economyMu.Unlock()
+
+	e := guildEconomy(cmd.GuildID)
+	find := func(id int) int {
+		return slices.IndexFunc(e.Ledger, func(tx Transaction) bool { return tx.ID == id })
+	}
+
+	index := find(id)
+	switch {
+	case index < 0:
+		cmd.Replyf("❌ No transaction #%d.", id)
+		return
+	case e.Ledger[index].Kind == txReverse:
+		cmd.Reply("❌ Reversals can't be reversed.")
+		return
+	case e.Ledger[index].Reversed:
+		cmd.Replyf("❌ Transaction #%d is already reversed.", id)
+		return
+	}
+
+	indexes := []int{index}
+	if tx := e.Ledger[index]; tx.Ref != 0 && (tx.Kind == txGive || tx.Kind == txReceive) {
+		if other := find(tx.Ref); other >= 0 && !e.Ledger[other].Reversed {
+			indexes = append(indexes, other)
+		}
+	}
+
+	var reversals []Transaction
+	for _, i := range indexes {
+		tx := e.Ledger[i]
+		reversals = append(reversals, Transaction{UserID: tx.UserID, Amount: -tx.Amount, Kind: txReverse, Ref: tx.ID})
+	}
+	recorded, err := e.apply(reversals...)
+	if err != nil {
+		cmd.Reply("❌ " + err.Error())
+		return
+	}
+	for _, i := range indexes {
+		e.Ledger[i].Reversed = true
+	}
+	if err := saveEconomy(); err != nil {
+		log.Printf("Failed to save economy: %v", err)
+	}
+
+	log.Printf("%s reversed transaction #%d in guild %s", cmd.Author.ID, id, cmd.GuildID)
+	lines := make([]string, len(recorded))
+	for i, tx := range recorded {
+		lines[i] = tx.String()
+	}
+	cmd.Reply("↩️ Reversed:\n" + strings.Join(lines, "\n"))
+}
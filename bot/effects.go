@@ -0,0 +1,133 @@
+package bot
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Built-in effect presets as ffmpeg -af filter graphs. Pitch and speed
+// changes resample to 48kHz first so asetrate works from a known rate.
+// Presets in the config's effect_presets override these by name
+var builtinEffects = map[string]string{
+	"bassboost": "bass=g=12:f=110:w=0.6",
+	"nightcore": "aresample=48000,asetrate=60000,aresample=48000",
+	"vaporwave": "aresample=48000,asetrate=38400,aresample=48000,aecho=0.8:0.9:500:0.3",
+	"8d":        "apulsator=hz=0.125",
+	"echo":      "aecho=0.8:0.88:60:0.4",
+	"reverb":    "aecho=0.8:0.9:1000|1800:0.3|0.25",
+	"speed":     "atempo=1.25",
+	"slow":      "atempo=0.8",
+	"pitch":     "aresample=48000,asetrate=53760,aresample=48000,atempo=0.892857",
+}
+
+// Look up a preset, config entries win over built-ins
+func effectPreset(name string) (string, bool) {
+	configMu.RLock()
+	graph, ok := botConfig.EffectPresets[name]
+	configMu.RUnlock()
+
+	if ok && graph != "" {
+		return graph, true
+	}
+	graph, ok = builtinEffects[name]
+	return graph, ok
+}
+
+// Sorted names of every available preset
+func effectPresetNames() []string {
+	seen := make(map[string]bool)
+	for name := range builtinEffects {
+		seen[name] = true
+	}
+
+	configMu.RLock()
+	for name, graph := range botConfig.EffectPresets {
+		if graph != "" {
+			seen[name] = true
+		}
+	}
+	configMu.RUnlock()
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// How fast a filter chain plays the track, 1.25 means 1.25s of track per
+// second heard. Only atempo and asetrate change it, asetrate relative to
+// the 48kHz the presets resample to first
+func filterTempo(filters []string) float64 {
+	tempo := 1.0
+	for _, filter := range strings.Split(strings.Join(filters, ","), ",") {
+		name, args, _ := strings.Cut(strings.TrimSpace(filter), "=")
+		// The first option, named ("r=60000") or not ("60000")
+		value, _, _ := strings.Cut(args, ":")
+		if _, named, ok := strings.Cut(value, "="); ok {
+			value = named
+		}
+
+		factor, err := strconv.ParseFloat(value, 64)
+		if err != nil || factor <= 0 {
+			continue
+		}
+		switch name {
+		case "atempo":
+			tempo *= factor
+		case "asetrate":
+			tempo *= factor / sampleRate
+		}
+	}
+	return tempo
+}
+
+// Restart the current track at its position so a filter change is heard
+// right away, returns false when nothing is playing. The position is in
+// track time, so a tempo change lands where the track was
+func restartCurrentTrack(guildID string) bool {
+	session, ok := getVoiceSession(guildID)
+	if !ok {
+		return false
+	}
+	return session.seek(session.currentPosition()) == nil
+}
+
+func effectsHandler(cmd *CommandContext) {
+	guildID := cmd.GuildID
+	name := strings.ToLower(cmd.String("effect"))
+
+	if name == "" {
+		current := getAudioSettings(guildID).Effect
+		if current == "" {
+			current = "off"
+		}
+		cmd.Replyf("🎛️ Current effect: **%s**\nAvailable: %s, off", current, strings.Join(effectPresetNames(), ", "))
+		return
+	}
+
+	if name == "off" {
+		name = ""
+	} else if _, ok := effectPreset(name); !ok {
+		cmd.Replyf("❌ Unknown effect **%s**. Available: %s, off", name, strings.Join(effectPresetNames(), ", "))
+		return
+	}
+
+	if err := updateAudioSettings(guildID, func(s *AudioSettings) { s.Effect = name }); err != nil {
+		cmd.Reply("❌ Failed to save audio settings: " + err.Error())
+		return
+	}
+
+	applied := "from the next track"
+	if restartCurrentTrack(guildID) {
+		applied = "to the current track"
+	}
+
+	if name == "" {
+		cmd.Reply("🎛️ Effects disabled, applied " + applied + ".")
+		return
+	}
+	cmd.Replyf("🎛️ Effect **%s** enabled, applied %s.", name, applied)
+}
@@ -0,0 +1,101 @@
+package bot
+
+import (
+	"fmt"
+	"github.com/hraban/opus"
+	"log"
+	"time"
+)
+
+const (
+	sampleRate    = 48000
+	channels      = 2
+	maxOpusPacket = 4000 // recommended upper bound for one encoded packet
+
+	defaultBitrate     = 64000
+	defaultFrameSizeMs = 20
+	defaultApplication = "audio"
+)
+
+var opusApplications = map[string]opus.Application{
+	"voip":     opus.AppVoIP,
+	"audio":    opus.AppAudio,
+	"lowdelay": opus.AppRestrictedLowdelay,
+}
+
+// Fill in defaults and replace invalid values so sessions can trust the
+// config. A frame size other than 20ms is an error, it would play at the
+// wrong speed
+func normalizeEncoderSettings(settings EncoderSettings) (EncoderSettings, error) {
+	if settings.Bitrate <= 0 {
+		settings.Bitrate = defaultBitrate
+	}
+
+	// discordgo's sender paces packets every 20ms regardless of their length
+	switch settings.FrameSizeMs {
+	case 0:
+		settings.FrameSizeMs = defaultFrameSizeMs
+	case defaultFrameSizeMs:
+	default:
+		return settings, fmt.Errorf("encoder frame_size_ms must be %d, discordgo sends one packet every %dms, got %d",
+			defaultFrameSizeMs, defaultFrameSizeMs, settings.FrameSizeMs)
+	}
+
+	if _, ok := opusApplications[settings.Application]; !ok {
+		if settings.Application != "" {
+			log.Printf("Unknown encoder application %q, using %q", settings.Application, defaultApplication)
+		}
+		settings.Application = defaultApplication
+	}
+
+	return settings, nil
+}
+
+// Encoder settings from the config, already normalized by loadBotConfig
+func encoderSettings() EncoderSettings {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return botConfig.Encoder
+}
+
+// Number of int16 values (both channels) in one frame
+func (settings EncoderSettings) frameSamples() int {
+	return sampleRate / 1000 * settings.FrameSizeMs * channels
+}
+
+func (settings EncoderSettings) frameDuration() time.Duration {
+	return time.Duration(settings.FrameSizeMs) * time.Millisecond
+}
+
+// Get the session's encoder, creating it from the current config on first use.
+// Only the queue worker calls this, so it needs no locking
+func (vs *VoiceSession) opusEncoder(settings EncoderSettings) (*opus.Encoder, error) {
+	if vs.encoder != nil {
+		return vs.encoder, nil
+	}
+
+	encoder, err := opus.NewEncoder(sampleRate, channels, opusApplications[settings.Application])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create opus encoder: %w", err)
+	}
+	if err := encoder.SetBitrate(settings.Bitrate); err != nil {
+		return nil, fmt.Errorf("failed to set opus bitrate %d: %w", settings.Bitrate, err)
+	}
+
+	vs.encoder = encoder
+	return encoder, nil
+}
+
+func (vs *VoiceSession) pcmToOpus(pcm []int16, settings EncoderSettings) ([]byte, error) {
+	encoder, err := vs.opusEncoder(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	opusBuf := make([]byte, maxOpusPacket)
+	n, err := encoder.Encode(pcm, opusBuf)
+	if err != nil {
+		return nil, fmt.Errorf("opus encode failed: %w", err)
+	}
+	return opusBuf[:n], nil
+}
@@ -1,6 +1,7 @@
 package bot
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -41,6 +42,18 @@ func removeTempFile(guildID, filename string) {
 	os.Remove(filename)
 }
 
+func isTempFile(guildID, filename string) bool {
+	tempFilesMu.RLock()
+	defer tempFilesMu.RUnlock()
+
+	for _, file := range tempFiles[guildID] {
+		if file == filename {
+			return true
+		}
+	}
+	return false
+}
+
 func cleanupTempFiles(guildID string) {
 	tempFilesMu.Lock()
 	defer tempFilesMu.Unlock()
@@ -118,7 +131,11 @@ func waitForfileReady(filename string, maxWait time.Duration) error {
 	return fmt.Errorf("file %s not ready after %v (last size: %d)", filename, maxWait, lastSize)
 }
 
-func downloadAttachment(url, filename string) (string, error) {
+var errDownloadTooLarge = errors.New("download is too large")
+
+// Download a file into the temp directory. A positive maxBytes stops the
+// download past that size with errDownloadTooLarge
+func downloadAttachment(url, filename string, maxBytes int64) (string, error) {
 	resp, err := http.Get(url)
 	if err != nil {
 		return "", err
@@ -132,6 +149,13 @@ func downloadAttachment(url, filename string) (string, error) {
 	}
 	defer out.Close()
 
-	_, err = io.Copy(out, resp.Body)
+	if maxBytes <= 0 {
+		_, err = io.Copy(out, resp.Body)
+		return localPath, err
+	}
+	n, err := io.Copy(out, io.LimitReader(resp.Body, maxBytes+1))
+	if err == nil && n > maxBytes {
+		err = errDownloadTooLarge
+	}
 	return localPath, err
 }
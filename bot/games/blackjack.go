@@ -0,0 +1,114 @@
+package games
+
+// BlackjackState is where a blackjack hand is at
+type BlackjackState int
+
+const (
+	BlackjackPlaying BlackjackState = iota
+	BlackjackNatural                // player was dealt 21, pays 3:2
+	BlackjackWin
+	BlackjackPush
+	BlackjackLoss
+)
+
+// The dealer draws to this total and stands on it, soft totals included
+const dealerStands = 17
+
+// Blackjack is one player's hand against the dealer
+type Blackjack struct {
+	Player []Card
+	Dealer []Card
+	State  BlackjackState
+	deck   *Deck
+}
+
+// Deal two cards each. The dealer peeks, so a natural 21 on either side
+// settles the hand immediately
+func NewBlackjack(rng Rand) *Blackjack {
+	return dealBlackjack(NewDeck(rng))
+}
+
+func dealBlackjack(deck *Deck) *Blackjack {
+	b := &Blackjack{deck: deck}
+	b.Player = []Card{b.deck.Draw(), b.deck.Draw()}
+	b.Dealer = []Card{b.deck.Draw(), b.deck.Draw()}
+
+	player, dealer := HandValue(b.Player), HandValue(b.Dealer)
+	switch {
+	case player == 21 && dealer == 21:
+		b.State = BlackjackPush
+	case player == 21:
+		b.State = BlackjackNatural
+	case dealer == 21:
+		b.State = BlackjackLoss
+	}
+	return b
+}
+
+// HandValue is the best blackjack total, counting aces as 11 when that
+// doesn't bust
+func HandValue(cards []Card) int {
+	total, aces := 0, 0
+	for _, card := range cards {
+		switch {
+		case card.Rank == 1:
+			aces++
+			total++
+		case card.Rank > 10:
+			total += 10
+		default:
+			total += card.Rank
+		}
+	}
+	if aces > 0 && total+10 <= 21 {
+		total += 10
+	}
+	return total
+}
+
+// Hit draws a card for the player, busting over 21 loses
+func (b *Blackjack) Hit() {
+	if b.State != BlackjackPlaying {
+		return
+	}
+	b.Player = append(b.Player, b.deck.Draw())
+	switch total := HandValue(b.Player); {
+	case total > 21:
+		b.State = BlackjackLoss
+	case total == 21:
+		b.Stand()
+	}
+}
+
+// Stand ends the player's turn, the dealer draws to 17 and the hands compare
+func (b *Blackjack) Stand() {
+	if b.State != BlackjackPlaying {
+		return
+	}
+	for HandValue(b.Dealer) < dealerStands {
+		b.Dealer = append(b.Dealer, b.deck.Draw())
+	}
+
+	player, dealer := HandValue(b.Player), HandValue(b.Dealer)
+	switch {
+	case dealer > 21 || player > dealer:
+		b.State = BlackjackWin
+	case player == dealer:
+		b.State = BlackjackPush
+	default:
+		b.State = BlackjackLoss
+	}
+}
+
+// Payout is what a settled hand returns for the bet, stake included
+func (b *Blackjack) Payout(bet int64) int64 {
+	switch b.State {
+	case BlackjackNatural:
+		return bet + bet*3/2
+	case BlackjackWin:
+		return 2 * bet
+	case BlackjackPush:
+		return bet
+	}
+	return 0
+}
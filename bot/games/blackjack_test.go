@@ -0,0 +1,109 @@
+package games
+
+import "testing"
+
+func cards(ranks ...int) []Card {
+	out := make([]Card, len(ranks))
+	for i, rank := range ranks {
+		out[i] = Card{Rank: rank}
+	}
+	return out
+}
+
+// Deal player, player, dealer, dealer, then the rest in order
+func stackedBlackjack(ranks ...int) *Blackjack {
+	return dealBlackjack(stackedDeck(cards(ranks...)...))
+}
+
+func TestBlackjackNaturals(t *testing.T) {
+	tests := []struct {
+		name  string
+		ranks []int
+		want  BlackjackState
+		pays  int64
+	}{
+		{"player natural", []int{1, 13, 10, 9}, BlackjackNatural, 250},
+		{"both natural", []int{1, 12, 1, 10}, BlackjackPush, 100},
+		{"dealer natural", []int{10, 9, 1, 11}, BlackjackLoss, 0},
+		{"no natural", []int{10, 9, 10, 7}, BlackjackPlaying, 0},
+	}
+	for _, tt := range tests {
+		b := stackedBlackjack(tt.ranks...)
+		if b.State != tt.want {
+			t.Errorf("%s: state %d, want %d", tt.name, b.State, tt.want)
+		}
+		if got := b.Payout(100); got != tt.pays {
+			t.Errorf("%s: payout %d, want %d", tt.name, got, tt.pays)
+		}
+	}
+}
+
+func TestBlackjackBust(t *testing.T) {
+	b := stackedBlackjack(10, 6, 10, 7, 9)
+	b.Hit()
+	if b.State != BlackjackLoss {
+		t.Fatalf("state %d after busting, want loss", b.State)
+	}
+	if len(b.Dealer) != 2 {
+		t.Errorf("dealer drew %d cards after a player bust", len(b.Dealer)-2)
+	}
+	if got := b.Payout(100); got != 0 {
+		t.Errorf("payout %d, want 0", got)
+	}
+
+	// A settled hand ignores more moves
+	b.Hit()
+	if len(b.Player) != 3 {
+		t.Errorf("hit after a bust drew a card")
+	}
+}
+
+func TestBlackjackHitToTwentyOneStands(t *testing.T) {
+	b := stackedBlackjack(10, 5, 10, 7, 6)
+	b.Hit()
+	if b.State != BlackjackWin {
+		t.Fatalf("state %d after hitting 21 against 17, want win", b.State)
+	}
+}
+
+func TestBlackjackDealerStandsOnSeventeen(t *testing.T) {
+	tests := []struct {
+		name   string
+		ranks  []int
+		dealer int // cards the dealer ends with
+		want   BlackjackState
+		pays   int64
+	}{
+		{"hard 17", []int{10, 8, 10, 7, 5}, 2, BlackjackWin, 200},
+		{"soft 17", []int{10, 8, 1, 6, 5}, 2, BlackjackWin, 200},
+		{"push on 17", []int{10, 7, 10, 7, 5}, 2, BlackjackPush, 100},
+		{"draws under 17", []int{10, 9, 10, 6, 2}, 3, BlackjackWin, 200},
+		{"draws to 21", []int{10, 8, 10, 6, 5}, 3, BlackjackLoss, 0},
+		{"dealer busts", []int{10, 2, 10, 6, 9}, 3, BlackjackWin, 200},
+		{"dealer beats", []int{10, 7, 10, 8}, 2, BlackjackLoss, 0},
+	}
+	for _, tt := range tests {
+		b := stackedBlackjack(tt.ranks...)
+		b.Stand()
+		if len(b.Dealer) != tt.dealer {
+			t.Errorf("%s: dealer has %s, want %d cards", tt.name, Hand(b.Dealer), tt.dealer)
+		}
+		if b.State != tt.want {
+			t.Errorf("%s: state %d, want %d", tt.name, b.State, tt.want)
+		}
+		if got := b.Payout(100); got != tt.pays {
+			t.Errorf("%s: payout %d, want %d", tt.name, got, tt.pays)
+		}
+	}
+}
+
+func TestBlackjackReplaysWithSameSeed(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		a, b := NewBlackjack(seeded(seed)), NewBlackjack(seeded(seed))
+		a.Stand()
+		b.Stand()
+		if Hand(a.Player) != Hand(b.Player) || Hand(a.Dealer) != Hand(b.Dealer) || a.State != b.State {
+			t.Fatalf("seed %d played out differently", seed)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Package games holds the rules of the casino games, free of Discord and
+// wallets. Every game draws from the Rand it is given, so a seeded
+// source replays the same game
+package games
+
+import "strings"
+
+// Rand is the randomness the games draw from, *rand.Rand satisfies it
+type Rand interface {
+	Intn(n int) int
+	Shuffle(n int, swap func(i, j int))
+}
+
+var (
+	suits = []string{"♠️", "♥️", "♦️", "♣️"}
+	ranks = []string{"A", "2", "3", "4", "5", "6", "7", "8", "9", "10", "J", "Q", "K"}
+)
+
+// Card is one playing card, Rank 1 is the ace and 11-13 the face cards
+type Card struct {
+	Rank int
+	Suit int
+}
+
+func (c Card) String() string {
+	return ranks[c.Rank-1] + suits[c.Suit]
+}
+
+// Deck is a shuffled 52 card deck, dealt from the top
+type Deck struct {
+	cards []Card
+	rng   Rand
+}
+
+func NewDeck(rng Rand) *Deck {
+	d := &Deck{rng: rng}
+	d.reset()
+	return d
+}
+
+func (d *Deck) reset() {
+	d.cards = d.cards[:0]
+	for suit := range suits {
+		for rank := 1; rank <= len(ranks); rank++ {
+			d.cards = append(d.cards, Card{Rank: rank, Suit: suit})
+		}
+	}
+	d.rng.Shuffle(len(d.cards), func(i, j int) {
+		d.cards[i], d.cards[j] = d.cards[j], d.cards[i]
+	})
+}
+
+// Draw the top card, a fresh shuffled deck replaces an empty one
+func (d *Deck) Draw() Card {
+	if len(d.cards) == 0 {
+		d.reset()
+	}
+	card := d.cards[len(d.cards)-1]
+	d.cards = d.cards[:len(d.cards)-1]
+	return card
+}
+
+// Hand renders cards as "A♠️ 10♥️"
+func Hand(cards []Card) string {
+	names := make([]string, len(cards))
+	for i, card := range cards {
+		names[i] = card.String()
+	}
+	return strings.Join(names, " ")
+}
@@ -0,0 +1,80 @@
+package games
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func seeded(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
+}
+
+// A deck that deals the given cards in order, then reshuffles from seed 1
+func stackedDeck(cards ...Card) *Deck {
+	d := &Deck{rng: seeded(1)}
+	for i := len(cards) - 1; i >= 0; i-- {
+		d.cards = append(d.cards, cards[i])
+	}
+	return d
+}
+
+func TestDeckHasEveryCardOnce(t *testing.T) {
+	d := NewDeck(seeded(42))
+	seen := make(map[Card]bool)
+	for range 52 {
+		card := d.Draw()
+		if seen[card] {
+			t.Fatalf("%s dealt twice", card)
+		}
+		seen[card] = true
+	}
+	if len(seen) != 52 {
+		t.Fatalf("dealt %d distinct cards, want 52", len(seen))
+	}
+
+	// An empty deck reshuffles instead of running out
+	d.Draw()
+}
+
+func TestDeckReplaysWithSameSeed(t *testing.T) {
+	a, b := NewDeck(seeded(7)), NewDeck(seeded(7))
+	if !slices.Equal(a.cards, b.cards) {
+		t.Fatal("decks with the same seed differ")
+	}
+	if c := NewDeck(seeded(8)); slices.Equal(a.cards, c.cards) {
+		t.Fatal("decks with different seeds are the same")
+	}
+}
+
+func TestHandValue(t *testing.T) {
+	tests := []struct {
+		ranks []int
+		want  int
+	}{
+		{[]int{1, 13}, 21},
+		{[]int{1, 1}, 12},
+		{[]int{1, 6}, 17},
+		{[]int{1, 6, 10}, 17},
+		{[]int{11, 12, 2}, 22},
+		{[]int{1, 1, 9}, 21},
+	}
+	for _, tt := range tests {
+		cards := make([]Card, len(tt.ranks))
+		for i, rank := range tt.ranks {
+			cards[i] = Card{Rank: rank}
+		}
+		if got := HandValue(cards); got != tt.want {
+			t.Errorf("HandValue(%s) = %d, want %d", Hand(cards), got, tt.want)
+		}
+	}
+}
+
+func TestCardString(t *testing.T) {
+	if got := (Card{Rank: 1, Suit: 0}).String(); got != "A♠️" {
+		t.Errorf("got %q, want A♠️", got)
+	}
+	if got := Hand([]Card{{Rank: 10, Suit: 1}, {Rank: 13, Suit: 3}}); got != "10♥️ K♣️" {
+		t.Errorf("got %q, want 10♥️ K♣️", got)
+	}
+}
@@ -0,0 +1,45 @@
+package games
+
+// Coin sides
+const (
+	Heads = "heads"
+	Tails = "tails"
+)
+
+// FlipCoin lands on Heads or Tails
+func FlipCoin(rng Rand) string {
+	if rng.Intn(2) == 0 {
+		return Heads
+	}
+	return Tails
+}
+
+// DiceRoll is a pair of six-sided dice
+type DiceRoll [2]int
+
+func RollDice(rng Rand) DiceRoll {
+	return DiceRoll{rng.Intn(6) + 1, rng.Intn(6) + 1}
+}
+
+func (r DiceRoll) Total() int {
+	return r[0] + r[1]
+}
+
+// DiceDuel settles a duel between a challenger and an opponent. Winner is
+// 1 for the challenger, 2 for the opponent and 0 for a tie
+type DiceDuel struct {
+	Challenger DiceRoll
+	Opponent   DiceRoll
+	Winner     int
+}
+
+func RollDuel(rng Rand) DiceDuel {
+	duel := DiceDuel{Challenger: RollDice(rng), Opponent: RollDice(rng)}
+	switch {
+	case duel.Challenger.Total() > duel.Opponent.Total():
+		duel.Winner = 1
+	case duel.Opponent.Total() > duel.Challenger.Total():
+		duel.Winner = 2
+	}
+	return duel
+}
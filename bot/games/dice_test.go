@@ -0,0 +1,78 @@
+package games
+
+import "testing"
+
+// Rand that returns fixed Intn values in order
+type scripted struct {
+	values []int
+}
+
+func (s *scripted) Intn(n int) int {
+	v := s.values[0]
+	s.values = s.values[1:]
+	return v % n
+}
+
+func (s *scripted) Shuffle(int, func(i, j int)) {}
+
+func TestFlipCoin(t *testing.T) {
+	counts := make(map[string]int)
+	rng := seeded(3)
+	for range 1000 {
+		counts[FlipCoin(rng)]++
+	}
+	if len(counts) != 2 || counts[Heads] < 400 || counts[Tails] < 400 {
+		t.Fatalf("unfair flips: %v", counts)
+	}
+
+	a, b := seeded(9), seeded(9)
+	for range 50 {
+		if FlipCoin(a) != FlipCoin(b) {
+			t.Fatal("same seed flipped differently")
+		}
+	}
+}
+
+func TestRollDuel(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int
+		want   int
+	}{
+		{"challenger", []int{5, 5, 0, 0}, 1},
+		{"opponent", []int{0, 1, 3, 2}, 2},
+		{"tie", []int{2, 3, 4, 1}, 0},
+	}
+	for _, tt := range tests {
+		duel := RollDuel(&scripted{values: tt.values})
+		if duel.Winner != tt.want {
+			t.Errorf("%s: %v vs %v, winner %d, want %d", tt.name, duel.Challenger, duel.Opponent, duel.Winner, tt.want)
+		}
+	}
+}
+
+func TestRollDuelSeeded(t *testing.T) {
+	rng := seeded(11)
+	var ties int
+	for range 1000 {
+		duel := RollDuel(rng)
+		for _, roll := range []DiceRoll{duel.Challenger, duel.Opponent} {
+			if roll[0] < 1 || roll[0] > 6 || roll[1] < 1 || roll[1] > 6 {
+				t.Fatalf("impossible roll %v", roll)
+			}
+		}
+		c, o := duel.Challenger.Total(), duel.Opponent.Total()
+		switch {
+		case c == o:
+			ties++
+			if duel.Winner != 0 {
+				t.Fatalf("tie %d-%d has winner %d", c, o, duel.Winner)
+			}
+		case (c > o) != (duel.Winner == 1):
+			t.Fatalf("%d-%d has winner %d", c, o, duel.Winner)
+		}
+	}
+	if ties == 0 {
+		t.Fatal("no ties in 1000 duels")
+	}
+}
@@ -0,0 +1,60 @@
+package games
+
+import "math"
+
+// The share of every priced guess the house keeps
+const higherLowerEdge = 0.05
+
+// HigherLower has the player guess whether the next card ranks higher or
+// lower than the current one. Every right guess raises the payout by its
+// odds, one wrong guess loses it all and an equal rank is a free redraw
+type HigherLower struct {
+	Current    Card
+	Streak     int     // right guesses so far
+	Multiplier float64 // what cashing out returns per coin bet
+	Lost       bool    // a guess was wrong
+	deck       *Deck
+}
+
+func NewHigherLower(rng Rand) *HigherLower {
+	h := &HigherLower{Multiplier: 1, deck: NewDeck(rng)}
+	h.Current = h.deck.Draw()
+	return h
+}
+
+// Guess draws the next card and returns it along with whether the guess was
+// right. Equal ranks count as neither. A right guess multiplies the payout
+// by (1-edge)/p, p being the chance of the guess out of the 12 other ranks,
+// so a guess that can't lose pays nothing extra
+func (h *HigherLower) Guess(higher bool) (Card, bool) {
+	previous := h.Current
+	h.Current = h.deck.Draw()
+
+	winning := previous.Rank - 1 // ranks below
+	if higher {
+		winning = len(ranks) - previous.Rank
+	}
+
+	switch {
+	case h.Current.Rank == previous.Rank:
+		return h.Current, true
+	case (h.Current.Rank > previous.Rank) == higher:
+		h.Streak++
+		if other := len(ranks) - 1; winning < other {
+			h.Multiplier *= (1 - higherLowerEdge) * float64(other) / float64(winning)
+		}
+		return h.Current, true
+	}
+	h.Lost = true
+	return h.Current, false
+}
+
+// Payout is what cashing out returns for the bet, rounded down, nothing
+// after a wrong guess
+func (h *HigherLower) Payout(bet int64) int64 {
+	if h.Lost {
+		return 0
+	}
+	// The nudge keeps float error from rounding an even 1.9× down
+	return int64(math.Floor(float64(bet)*h.Multiplier + 1e-9))
+}
@@ -0,0 +1,103 @@
+package games
+
+import (
+	"math"
+	"testing"
+)
+
+// A game showing the first rank, then dealing the rest in order
+func stackedHigherLower(ranks ...int) *HigherLower {
+	h := &HigherLower{Multiplier: 1, deck: stackedDeck(cards(ranks...)...)}
+	h.Current = h.deck.Draw()
+	return h
+}
+
+func TestHigherLowerTieIsRedraw(t *testing.T) {
+	h := stackedHigherLower(7, 7)
+	card, right := h.Guess(true)
+	if card.Rank != 7 || !right {
+		t.Fatalf("got %s right=%t, want a 7 counted as a redraw", card, right)
+	}
+	if h.Streak != 0 || h.Multiplier != 1 || h.Lost {
+		t.Errorf("tie changed the game: streak %d, multiplier %f, lost %t", h.Streak, h.Multiplier, h.Lost)
+	}
+	if got := h.Payout(100); got != 100 {
+		t.Errorf("payout %d after a tie, want the bet back", got)
+	}
+}
+
+func TestHigherLowerLoss(t *testing.T) {
+	h := stackedHigherLower(7, 9, 3)
+	if _, right := h.Guess(true); !right {
+		t.Fatal("9 after 7 isn't higher")
+	}
+	if _, right := h.Guess(true); right {
+		t.Fatal("3 after 9 counted as higher")
+	}
+	if !h.Lost || h.Payout(100) != 0 {
+		t.Errorf("lost %t, payout %d, want everything lost", h.Lost, h.Payout(100))
+	}
+}
+
+func TestHigherLowerPricedGuess(t *testing.T) {
+	// 6 of the 12 other ranks are above a 7
+	h := stackedHigherLower(7, 10)
+	h.Guess(true)
+	if want := (1 - higherLowerEdge) * 2; math.Abs(h.Multiplier-want) > 1e-9 {
+		t.Fatalf("multiplier %f, want %f", h.Multiplier, want)
+	}
+	if got := h.Payout(100); got != 190 {
+		t.Errorf("payout %d, want 190", got)
+	}
+}
+
+func TestHigherLowerCertainGuessPaysNothing(t *testing.T) {
+	for _, tt := range []struct {
+		ranks  []int
+		higher bool
+	}{
+		{[]int{1, 5}, true},   // nothing ranks below an ace
+		{[]int{13, 5}, false}, // or above a king
+	} {
+		h := stackedHigherLower(tt.ranks...)
+		if _, right := h.Guess(tt.higher); !right {
+			t.Fatalf("certain guess from %d lost", tt.ranks[0])
+		}
+		if h.Payout(100) != 100 {
+			t.Errorf("certain guess from %d pays %d, want the bet back", tt.ranks[0], h.Payout(100))
+		}
+	}
+}
+
+// Every guess that can lose returns less than the stake on average
+func TestHigherLowerHouseEdge(t *testing.T) {
+	for rank := 2; rank <= 12; rank++ {
+		for _, higher := range []bool{true, false} {
+			var expected float64
+			for next := 1; next <= 13; next++ {
+				if next == rank {
+					continue
+				}
+				h := stackedHigherLower(rank, next)
+				if _, right := h.Guess(higher); right {
+					expected += h.Multiplier / 12
+				}
+			}
+			if math.Abs(expected-(1-higherLowerEdge)) > 1e-9 {
+				t.Errorf("guessing higher=%t from %d returns %f on average", higher, rank, expected)
+			}
+		}
+	}
+}
+
+func TestHigherLowerReplaysWithSameSeed(t *testing.T) {
+	a, b := NewHigherLower(seeded(5)), NewHigherLower(seeded(5))
+	for range 10 {
+		higher := a.Current.Rank < 7
+		ca, ra := a.Guess(higher)
+		cb, rb := b.Guess(higher)
+		if ca != cb || ra != rb {
+			t.Fatal("same seed dealt differently")
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package bot
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxPrefixLength = 5
+
+	// Channel rules under this key apply to every command
+	allCommands = "*"
+)
+
+// Get a guild's command settings, the zero value when none are stored
+func getGuildSettings(guildID string) GuildSettings {
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	return botConfig.Guilds[guildID]
+}
+
+// Apply a change to a guild's command settings and persist the config
+func updateGuildSettings(guildID string, update func(*GuildSettings)) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	// Copy the slice and map so readers holding the old value never see them change
+	settings := botConfig.Guilds[guildID]
+	settings.DisabledCommands = slices.Clone(settings.DisabledCommands)
+	settings.Channels = maps.Clone(settings.Channels)
+	settings.CommandLevels = maps.Clone(settings.CommandLevels)
+	settings.ProtectedUsers = slices.Clone(settings.ProtectedUsers)
+	settings.ProtectedChannels = slices.Clone(settings.ProtectedChannels)
+	update(&settings)
+	botConfig.Guilds[guildID] = settings
+
+	return saveBotConfig()
+}
+
+// The text prefix a guild uses, the default outside guilds or when unset
+func guildPrefix(guildID string) string {
+	if prefix := getGuildSettings(guildID).Prefix; prefix != "" {
+		return prefix
+	}
+	return defaultPrefix
+}
+
+// Every prefix a message in the guild may start a command with
+func commandPrefixes(discord *discordgo.Session, guildID string) []string {
+	prefixes := []string{guildPrefix(guildID)}
+	if getGuildSettings(guildID).MentionPrefix && discord.State.User != nil {
+		botID := discord.State.User.ID
+		prefixes = append(prefixes, "<@"+botID+">", "<@!"+botID+">")
+	}
+	return prefixes
+}
+
+// Why a command can't run in a channel, empty when it can. !config is never
+// blocked so admins can't lock themselves out
+func commandBlocked(command *Command, guildID, channelID string) string {
+	if guildID == "" || command.Name == "config" {
+		return ""
+	}
+
+	settings := getGuildSettings(guildID)
+	if slices.Contains(settings.DisabledCommands, command.Name) {
+		return "is disabled in this server"
+	}
+
+	for _, key := range []string{allCommands, command.Name} {
+		rules, ok := settings.Channels[key]
+		if !ok {
+			continue
+		}
+		if slices.Contains(rules.Deny, channelID) {
+			return "can't be used in this channel"
+		}
+		if len(rules.Allow) > 0 && !slices.Contains(rules.Allow, channelID) {
+			return "can't be used in this channel"
+		}
+	}
+	return ""
+}
+
+func configSummary(guildID string) string {
+	settings := getGuildSettings(guildID)
+
+	mention := "off"
+	if settings.MentionPrefix {
+		mention = "on"
+	}
+
+	response := fmt.Sprintf("⚙️ **Server settings**\nPrefix: `%s`\nMention as prefix: **%s**\n", guildPrefix(guildID), mention)
+
+	disabled := "none"
+	if len(settings.DisabledCommands) > 0 {
+		disabled = strings.Join(settings.DisabledCommands, ", ")
+	}
+	response += "Disabled commands: " + disabled + "\n"
+	response += "DJ roles: " + roleMentions(settings.DJRoles) + "\n"
+	response += "Moderator roles: " + roleMentions(settings.ModRoles) + "\n"
+
+	if len(settings.CommandLevels) > 0 {
+		names := slices.Sorted(maps.Keys(settings.CommandLevels))
+		levels := make([]string, len(names))
+		for i, name := range names {
+			levels[i] = name + "=" + settings.CommandLevels[name]
+		}
+		response += "Permission overrides: " + strings.Join(levels, ", ") + "\n"
+	}
+
+	if settings.RandomSeed != nil {
+		response += "Random seed: **pinned** for debugging\n"
+	}
+
+	return response + channelRulesSummary(settings)
+}
+
+func channelRulesSummary(settings GuildSettings) string {
+	if len(settings.Channels) == 0 {
+		return "Channel rules: none"
+	}
+
+	keys := make([]string, 0, len(settings.Channels))
+	for key := range settings.Channels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	response := "Channel rules:\n"
+	for _, key := range keys {
+		rules := settings.Channels[key]
+		name := "all commands"
+		if key != allCommands {
+			name = key
+		}
+		if len(rules.Allow) > 0 {
+			response += fmt.Sprintf("• %s: only in %s\n", name, channelMentions(rules.Allow))
+		}
+		if len(rules.Deny) > 0 {
+			response += fmt.Sprintf("• %s: not in %s\n", name, channelMentions(rules.Deny))
+		}
+	}
+	return strings.TrimSuffix(response, "\n")
+}
+
+func channelMentions(ids []string) string {
+	mentions := make([]string, len(ids))
+	for i, id := range ids {
+		mentions[i] = "<#" + id + ">"
+	}
+	return strings.Join(mentions, ", ")
+}
+
+// Resolve a command name or alias from a !config argument
+func configCommandName(name string) (string, bool) {
+	command, ok := lookupCommand(strings.TrimPrefix(name, "!"))
+	if !ok {
+		return "", false
+	}
+	return command.Name, true
+}
+
+func configHandler(cmd *CommandContext) {
+	guildID := cmd.GuildID
+	args := strings.Fields(cmd.String("args"))
+
+	var err error
+	switch cmd.String("setting") {
+	case "":
+		cmd.Reply(configSummary(guildID))
+		return
+
+	case "prefix":
+		if len(args) != 1 {
+			cmd.UsageError("prefix needs a new prefix or reset")
+			return
+		}
+		prefix := args[0]
+		if prefix == "reset" {
+			prefix = ""
+		} else if len([]rune(prefix)) > maxPrefixLength || strings.IndexFunc(prefix, unicode.IsSpace) >= 0 {
+			cmd.Replyf("❌ Prefixes are at most %d characters with no spaces.", maxPrefixLength)
+			return
+		}
+
+		err = updateGuildSettings(guildID, func(s *GuildSettings) { s.Prefix = prefix })
+		if err == nil {
+			cmd.Replyf("✅ Prefix set to `%s`.", guildPrefix(guildID))
+		}
+
+	case "mention":
+		if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+			cmd.UsageError("mention needs on or off")
+			return
+		}
+		enabled := args[0] == "on"
+
+		err = updateGuildSettings(guildID, func(s *GuildSettings) { s.MentionPrefix = enabled })
+		if err == nil {
+			cmd.Replyf("✅ Mention as prefix is now **%s**.", args[0])
+		}
+
+	case "disable", "enable":
+		if len(args) != 1 {
+			cmd.UsageError(cmd.String("setting") + " needs a command name")
+			return
+		}
+		name, ok := configCommandName(args[0])
+		if !ok {
+			cmd.Replyf("❌ Unknown command **%s**.", args[0])
+			return
+		}
+		if name == "config" {
+			cmd.Reply("❌ The config command can't be disabled.")
+			return
+		}
+
+		disable := cmd.String("setting") == "disable"
+		err = updateGuildSettings(guildID, func(s *GuildSettings) {
+			s.DisabledCommands = slices.DeleteFunc(s.DisabledCommands, func(n string) bool { return n == name })
+			if disable {
+				s.DisabledCommands = append(s.DisabledCommands, name)
+			}
+		})
+		if err == nil && disable {
+			cmd.Replyf("🚫 %s%s is now disabled.", guildPrefix(guildID), name)
+		} else if err == nil {
+			cmd.Replyf("✅ %s%s is enabled again.", guildPrefix(guildID), name)
+		}
+
+	case "channels":
+		err = configChannels(cmd, args)
+
+	case "level", "djrole", "modrole":
+		err = configPermissions(cmd, cmd.String("setting"), args)
+
+	case "seed":
+		err = configSeed(cmd, args)
+	}
+
+	if err != nil {
+		cmd.Reply("❌ Failed to save settings: " + err.Error())
+	}
+}
+
+// !config seed <number|off> pins the guild's random outcomes for debugging.
+// Anyone who knows the seed can predict games, so only bot owners may set it
+func configSeed(cmd *CommandContext, args []string) error {
+	if memberLevel(cmd.Discord, cmd.GuildID, cmd.ChannelID, cmd.Author.ID) < PermOwner {
+		cmd.Replyf("🔒 Setting the seed needs the **%s** permission level.", PermOwner)
+		return nil
+	}
+	if len(args) != 1 {
+		cmd.UsageError("seed needs a number or off")
+		return nil
+	}
+
+	var seed *int64
+	if args[0] != "off" {
+		n, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			cmd.UsageError("seed must be a whole number or off")
+			return nil
+		}
+		seed = &n
+	}
+
+	err := updateGuildSettings(cmd.GuildID, func(s *GuildSettings) { s.RandomSeed = seed })
+	if err != nil {
+		return err
+	}
+	resetSeedCounter(cmd.GuildID)
+
+	if seed == nil {
+		cmd.Reply("🎲 Random outcomes are crypto-seeded again.")
+	} else {
+		cmd.Replyf("🎲 Random outcomes now start from seed %d, every draw is logged.", *seed)
+	}
+	return nil
+}
+
+// !config channels [<command|*> <allow|deny|clear> [#channels...]]
+func configChannels(cmd *CommandContext, args []string) error {
+	guildID := cmd.GuildID
+
+	if len(args) == 0 {
+		cmd.Reply(channelRulesSummary(getGuildSettings(guildID)))
+		return nil
+	}
+	if len(args) < 2 {
+		cmd.UsageError("channels needs a command, then allow, deny or clear")
+		return nil
+	}
+
+	key := allCommands
+	if args[0] != allCommands {
+		name, ok := configCommandName(args[0])
+		if !ok {
+			cmd.Replyf("❌ Unknown command **%s**.", args[0])
+			return nil
+		}
+		key = name
+	}
+
+	action := strings.ToLower(args[1])
+	var channelIDs []string
+	for _, word := range args[2:] {
+		id, ok := mentionID(word, "#")
+		if !ok {
+			cmd.Replyf("❌ %s isn't a channel.", word)
+			return nil
+		}
+		channelIDs = append(channelIDs, id)
+	}
+
+	switch action {
+	case "allow", "deny":
+		if len(channelIDs) == 0 {
+			cmd.UsageError(action + " needs at least one channel")
+			return nil
+		}
+	case "clear":
+	default:
+		cmd.UsageError("channels takes allow, deny or clear")
+		return nil
+	}
+
+	err := updateGuildSettings(guildID, func(s *GuildSettings) {
+		if s.Channels == nil {
+			s.Channels = make(map[string]ChannelRules)
+		}
+		rules := s.Channels[key]
+		switch action {
+		case "allow":
+			rules.Allow = channelIDs
+		case "deny":
+			rules.Deny = channelIDs
+		case "clear":
+			rules = ChannelRules{}
+		}
+
+		if len(rules.Allow) == 0 && len(rules.Deny) == 0 {
+			delete(s.Channels, key)
+		} else {
+			s.Channels[key] = rules
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	cmd.Reply("✅ Updated. " + channelRulesSummary(getGuildSettings(guildID)))
+	return nil
+}
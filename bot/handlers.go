@@ -2,61 +2,54 @@ package bot
 
 import (
 	"context"
+	"discord-bot/bot/games"
 	"fmt"
 	"github.com/bwmarrin/discordgo"
 	"io"
 	"log"
-	"math/rand"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 )
 
-func sayHandler(discord *discordgo.Session, message *discordgo.MessageCreate, ttsText string) {
-	go func() {
-		filename := fmt.Sprintf("output_%d_%d.mp3", time.Now().Unix(), rand.Intn(10000))
-		guildID := message.GuildID
-		opID := fmt.Sprintf("tts_gamble_%s_%d", guildID, time.Now().Unix())
-		ctx := createOperationContext(opID)
-		defer removeOperationContext(opID)
+// Speak text in the caller's voice channel, the queue removes the clip after playback
+func sayHandler(cmd *CommandContext, ttsText string) {
+	filename := fmt.Sprintf("output_%d_%d.mp3", time.Now().Unix(), tempFileID())
+	guildID := cmd.GuildID
+	opID := fmt.Sprintf("tts_gamble_%s_%d", guildID, time.Now().Unix())
+	ctx := createOperationContext(opID)
+	defer removeOperationContext(opID)
 
-		log.Printf("TTS result: %s", ttsText)
-		err := synthesizeToMP3(ctx, ttsText, filename)
+	log.Printf("TTS result: %s", ttsText)
+	err := synthesizeToMP3(ctx, ttsText, filename)
 
-		if err != nil {
-			discord.ChannelMessageSend(message.ChannelID, "❌ TTS failed: "+err.Error())
-			return
-		}
+	if err != nil {
+		cmd.Reply("❌ TTS failed: " + err.Error())
+		return
+	}
 
-		if err := waitForfileReady(filename, 10*time.Second); err != nil {
-			discord.ChannelMessageSend(message.ChannelID, "❌ TTS file not ready.")
-			os.Remove(filename)
-			return
-		}
+	if err := waitForfileReady(filename, 10*time.Second); err != nil {
+		cmd.Reply("❌ TTS file not ready.")
+		os.Remove(filename)
+		return
+	}
 
-		addTempFile(guildID, filename)
+	addTempFile(guildID, filename)
 
-		if !botConnect(discord, message) {
-			removeTempFile(guildID, filename)
-			return
-		}
+	if !botConnect(cmd) {
+		removeTempFile(guildID, filename)
+		return
+	}
 
-		// Play synthesized audio
-		msg := &discordgo.MessageCreate{
-			Message: &discordgo.Message{
-				Content:   "!play " + filename,
-				ChannelID: message.ChannelID,
-				GuildID:   message.GuildID,
-			},
-		}
-		soundPlay(discord, msg)
+	session, ok := getVoiceSession(guildID)
+	if !ok {
+		removeTempFile(guildID, filename)
+		return
+	}
 
-		// Clean up file after a delay
-		time.AfterFunc(30*time.Second, func() {
-			removeTempFile(guildID, filename)
-		})
-	}()
+	// Queue the synthesized clip directly, the queue removes it after playback
+	enqueueTrack(cmd.Discord, session, newTrack(filename, cmd.ChannelID, ""))
 }
 
 func botIsInSameVoiceChannel(discord *discordgo.Session, guildID, userID string) bool {
@@ -66,249 +59,320 @@ func botIsInSameVoiceChannel(discord *discordgo.Session, guildID, userID string)
 		return false
 	}
 
-	botManager.mu.RLock()
-	session, ok := botManager.voiceConnections[guildID]
-	botManager.mu.RUnlock()
-
-	if !ok || session == nil || session.connection == nil {
+	session, ok := getVoiceSession(guildID)
+	if !ok {
 		return false
 	}
 
+	session.mu.RLock()
+	defer session.mu.RUnlock()
 	return session.connection.ChannelID == userVoiceState.ChannelID
 }
 
-func botConnect(discord *discordgo.Session, message *discordgo.MessageCreate) bool {
-	guildID := message.GuildID
+func botConnect(cmd *CommandContext) bool {
+	discord := cmd.Discord
+	guildID := cmd.GuildID
 
-	if botIsInSameVoiceChannel(discord, guildID, message.Author.ID) {
+	if botIsInSameVoiceChannel(discord, guildID, cmd.Author.ID) {
 		return true
 	}
 
 	if guildID == "" {
-		discord.ChannelMessageSend(message.ChannelID, "This command only works in a guild.")
+		cmd.Reply("This command only works in a guild.")
 		return false
 	}
 
-	voiceState, err := discord.State.VoiceState(guildID, message.Author.ID)
+	voiceState, err := discord.State.VoiceState(guildID, cmd.Author.ID)
 
 	if err != nil {
-		discord.ChannelMessageSend(message.ChannelID, "❌ Failed to get voice state.")
+		cmd.Reply("❌ Failed to get voice state.")
 		return false
 	}
 
 	if voiceState == nil || voiceState.ChannelID == "" {
-		discord.ChannelMessageSend(message.ChannelID, "User is not connected to a voice channel.")
+		cmd.Reply("User is not connected to a voice channel.")
 		return false
 	}
 
 	vc, err := discord.ChannelVoiceJoin(guildID, voiceState.ChannelID, false, false)
 	if err != nil {
-		discord.ChannelMessageSend(message.ChannelID, "❌ Failed to join voice channel.")
+		cmd.Reply("❌ Failed to join voice channel.")
 		return false
 	}
 
-	// Create new voice session with context
-	ctx, cancel := context.WithCancel(context.Background())
-	session := &VoiceSession{
-		connection: vc,
-		ctx:        ctx,
-		cancel:     cancel,
-		isPlaying:  false,
-	}
-
-	botManager.mu.Lock()
-	botManager.voiceConnections[guildID] = session
-	botManager.mu.Unlock()
-
+	attachVoiceSession(discord, guildID, vc)
 	return true
 }
 
-func joinSameChannel(discord *discordgo.Session, message *discordgo.MessageCreate) {
-
-	guildID := message.GuildID
+// Move everyone in voice to the given channel, or the caller's when none is given
+func joinSameChannel(cmd *CommandContext) {
+	discord := cmd.Discord
+	guildID := cmd.GuildID
 
-	go func() {
-
-		requesterVoiceState, err := discord.State.VoiceState(guildID, message.Author.ID)
+	targetChannelID := cmd.String("channel")
+	if targetChannelID != "" {
+		channel, err := discord.State.Channel(targetChannelID)
+		if err != nil || channel.GuildID != guildID || channel.Type != discordgo.ChannelTypeGuildVoice {
+			cmd.Reply("❌ That isn't a voice channel in this server.")
+			return
+		}
+	} else {
+		requesterVoiceState, err := discord.State.VoiceState(guildID, cmd.Author.ID)
 
 		if err != nil || requesterVoiceState == nil || requesterVoiceState.ChannelID == "" {
-			discord.ChannelMessageSend(message.ChannelID, "❌ You must be in a voice channel to use this command.")
+			cmd.Reply("❌ You must be in a voice channel to use this command.")
 			return
 		}
+		targetChannelID = requesterVoiceState.ChannelID
+	}
 
-		targetChannelID := requesterVoiceState.ChannelID
-		guild, err := discord.State.Guild(guildID)
+	if channelProtected(discord, guildID, targetChannelID) {
+		cmd.Replyf("🛡️ <#%s> is protected, nobody can be moved there.", targetChannelID)
+		return
+	}
 
-		if err != nil {
-			discord.ChannelMessageSend(message.ChannelID, "❌ Failed to get guild information.")
-			return
-		}
+	guild, err := discord.State.Guild(guildID)
 
-		var movedCount int
-		for _, vs := range guild.VoiceStates {
+	if err != nil {
+		cmd.Reply("❌ Failed to get guild information.")
+		return
+	}
 
-			if vs.UserID == message.Author.ID || vs.ChannelID == targetChannelID {
-				continue
-			}
+	snapshot := takeMoveSnapshot(discord, guildID, cmd.Command.Name)
+	defer snapshot.save(guildID)
 
-			err := discord.GuildMemberMove(guildID, vs.UserID, &targetChannelID)
+	var movedCount int
+	var skipped []skippedMove
+	for _, vs := range guild.VoiceStates {
 
-			if err != nil {
-				log.Printf("Failed to move user %s: %v", vs.UserID, err)
-			} else {
-				movedCount++
-			}
+		if vs.UserID == cmd.Author.ID || vs.ChannelID == targetChannelID {
+			continue
+		}
+		if reason := moveProtection(discord, guildID, vs.UserID, vs.ChannelID); reason != "" {
+			skipped = append(skipped, skippedMove{userID: vs.UserID, reason: reason})
+			continue
 		}
-		discord.ChannelMessageSend(message.ChannelID, fmt.Sprintf("📢 Moved %d user(s) to your voice channel.", movedCount))
-	}()
 
+		err := snapshot.move(discord, guildID, vs.UserID, targetChannelID)
+
+		if err != nil {
+			log.Printf("Failed to move user %s: %v", vs.UserID, err)
+		} else {
+			movedCount++
+		}
+	}
+	cmd.Replyf("📢 Moved %d user(s) to <#%s>.%s", movedCount, targetChannelID, skippedSummary(skipped))
 }
 
-func randomMoveSingle(discord *discordgo.Session, message *discordgo.MessageCreate) {
-	guildID := message.GuildID
+// Kick the target, or a random user from the caller's channel, into another voice channel
+func randomMoveSingle(cmd *CommandContext) {
+	discord := cmd.Discord
+	message := cmd.Message
+	guildID := cmd.GuildID
+	draws := newDraws(guildID, "shoot")
 
-	go func() {
-		// Get the current voice state of the requester
-		requesterVoiceState, err := discord.State.VoiceState(guildID, message.Author.ID)
+	// Get the current voice state of the requester
+	requesterVoiceState, err := discord.State.VoiceState(guildID, cmd.Author.ID)
 
-		if err != nil || requesterVoiceState == nil || requesterVoiceState.ChannelID == "" {
-			discord.ChannelMessageSend(message.ChannelID, "❌ You must be in a voice channel to use this command.")
-			return
-		}
+	if err != nil || requesterVoiceState == nil || requesterVoiceState.ChannelID == "" {
+		cmd.Reply("❌ You must be in a voice channel to use this command.")
+		return
+	}
 
-		// Now we need to know what channel the requester is in
-		targetChannelID := requesterVoiceState.ChannelID
+	// Now we need to know what channel the requester is in
+	targetChannelID := requesterVoiceState.ChannelID
 
-		voiceChannels, err := gatherVoiceChannels(discord, message, guildID)
-		if err != nil {
-			discord.ChannelMessageSend(message.ChannelID, "Failed to get voice channels: %v")
+	voiceChannels, err := gatherVoiceChannels(discord, message, guildID)
+	if err != nil {
+		cmd.Reply("❌ Failed to get voice channels.")
+		return
+	}
+
+	var selectedUserID string
+	var skipped []skippedMove
+	if cmd.Has("target") {
+		selectedUserID = cmd.String("target")
+		targetState, err := discord.State.VoiceState(guildID, selectedUserID)
+		if err != nil || targetState == nil || targetState.ChannelID == "" {
+			cmd.Reply("❌ That user isn't in a voice channel.")
 			return
 		}
-
+		targetChannelID = targetState.ChannelID
+		if reason := moveProtection(discord, guildID, selectedUserID, targetChannelID); reason != "" {
+			cmd.Replyf("🛡️ <@%s> can't be shot, they're %s.", selectedUserID, reason)
+			return
+		}
+	} else {
 		usersInVoice, err := gatherUsersVoiceStates(discord, message, guildID, targetChannelID)
 		if err != nil {
-			discord.ChannelMessageSend(message.ChannelID, "❌ Failure in gathering users in voice channels")
+			cmd.Reply("❌ Failure in gathering users in voice channels")
 			return
 		}
-
-		selectedVoice := usersInVoice[rand.Intn(len(usersInVoice))]
-
-		// Pick a random new channel (not the same one)
-		var possibleDestinations []string
-		for _, chID := range voiceChannels {
-			if chID != targetChannelID {
-				possibleDestinations = append(possibleDestinations, chID)
-			}
+		var movable []*discordgo.VoiceState
+		movable, skipped = filterMovable(discord, guildID, usersInVoice)
+		if len(movable) == 0 {
+			cmd.Reply("🛡️ Nobody in your channel can be shot." + skippedSummary(skipped))
+			return
 		}
+		selectedUserID = movable[draws.Intn(len(movable))].UserID
+	}
 
-		if len(possibleDestinations) == 0 {
-			discord.ChannelMessageSend(message.ChannelID, "❌ No other voice channels to move the user to.")
-			return
+	// Pick a random new channel (not the same one)
+	var possibleDestinations []string
+	for _, chID := range unprotectedChannels(discord, guildID, voiceChannels) {
+		if chID != targetChannelID {
+			possibleDestinations = append(possibleDestinations, chID)
 		}
+	}
 
-		newChannelID := possibleDestinations[rand.Intn(len(possibleDestinations))]
+	if len(possibleDestinations) == 0 {
+		cmd.Reply("❌ No other voice channels to move the user to.")
+		return
+	}
 
-		err = discord.GuildMemberMove(guildID, selectedVoice.UserID, &newChannelID)
-		if err != nil {
-			log.Printf("Failed to move user: %v", err)
-			discord.ChannelMessageSend(message.ChannelID, "❌ Failed to move the user.")
-			return
-		}
+	newChannelID := possibleDestinations[draws.Intn(len(possibleDestinations))]
+	draws.Log(fmt.Sprintf("%s shot %s into %s", cmd.Author.ID, selectedUserID, newChannelID))
 
-		discord.ChannelMessageSend(message.ChannelID, fmt.Sprintf("<@%s> 🔫 Has Been Shot", selectedVoice.UserID))
-	}()
+	snapshot := takeMoveSnapshot(discord, guildID, cmd.Command.Name)
+	err = snapshot.move(discord, guildID, selectedUserID, newChannelID)
+	snapshot.save(guildID)
+	if err != nil {
+		log.Printf("Failed to move user: %v", err)
+		cmd.Reply("❌ Failed to move the user.")
+		return
+	}
+
+	recordStat(guildID, cmd.Author.ID, statShotsFired, 1)
+	recordStat(guildID, selectedUserID, statTimesShot, 1)
+	cmd.Replyf("<@%s> 🔫 Has Been Shot%s", selectedUserID, skippedSummary(skipped))
 }
 
-func shuffleVoiceChannels(discord *discordgo.Session, message *discordgo.MessageCreate) {
-	guildID := message.GuildID
+func shuffleVoiceChannels(cmd *CommandContext) {
+	discord := cmd.Discord
+	guildID := cmd.GuildID
 
-	// Run in goroutine to avoid blocking
-	go func() {
+	voiceChannels, err := gatherVoiceChannels(discord, cmd.Message, guildID)
+	if err != nil {
+		cmd.Reply("❌ Failed to get voice channels.")
+		return
+	}
+	usersInVoice, err := gatherUsersVoiceStates(discord, cmd.Message, guildID, "")
+	if err != nil {
+		cmd.Reply("❌ Failed to get users voice states.")
+		return
+	}
 
-		voiceChannels, err := gatherVoiceChannels(discord, message, guildID)
-		if err != nil {
-			discord.ChannelMessageSend(message.ChannelID, "Failed to get voice channels: %v")
-		}
-		usersInVoice, err := gatherUsersVoiceStates(discord, message, guildID, "")
-		if err != nil {
-			discord.ChannelMessageSend(message.ChannelID, "Failed to get users voice states: %v")
-		}
+	usersInVoice, skipped := filterMovable(discord, guildID, usersInVoice)
+	voiceChannels = unprotectedChannels(discord, guildID, voiceChannels)
 
-		if len(usersInVoice) < 1 {
-			discord.ChannelMessageSend(message.ChannelID, "❌ No users in voice channels to shuffle.")
-			return
-		}
+	if len(usersInVoice) < 1 {
+		cmd.Reply("❌ No users in voice channels to shuffle." + skippedSummary(skipped))
+		return
+	}
+	if len(voiceChannels) == 0 {
+		cmd.Reply("❌ Every voice channel is protected.")
+		return
+	}
+
+	draws := newDraws(guildID, "shuffle")
+	draws.Shuffle(len(usersInVoice), func(i, j int) {
+		usersInVoice[i], usersInVoice[j] = usersInVoice[j], usersInVoice[i]
+	})
+	draws.Log(fmt.Sprintf("%d user(s) over %d channel(s)", len(usersInVoice), len(voiceChannels)))
 
-		rand.Shuffle(len(usersInVoice), func(i, j int) {
-			usersInVoice[i], usersInVoice[j] = usersInVoice[j], usersInVoice[i]
-		})
+	snapshot := takeMoveSnapshot(discord, guildID, cmd.Command.Name)
+	defer snapshot.save(guildID)
 
-		for i, vs := range usersInVoice {
-			targetChannel := voiceChannels[i%len(voiceChannels)]
-			err := discord.GuildMemberMove(guildID, vs.UserID, &targetChannel)
-			if err != nil {
-				log.Printf("Failed to move user %s: %v", vs.UserID, err)
-			}
+	for i, vs := range usersInVoice {
+		targetChannel := voiceChannels[i%len(voiceChannels)]
+		err := snapshot.move(discord, guildID, vs.UserID, targetChannel)
+		if err != nil {
+			log.Printf("Failed to move user %s: %v", vs.UserID, err)
 		}
+	}
 
-		discord.ChannelMessageSend(message.ChannelID, "🔀 Shuffled users into random voice channels.")
-	}()
+	cmd.Reply("🔀 Shuffled users into random voice channels." + skippedSummary(skipped))
 }
 
-func slotMachine(discord *discordgo.Session, message *discordgo.MessageCreate) {
+// The reels, in the order payouts are listed
+var slotIcons = []string{
+	"🍒",   // Cherries
+	"🍋",   // Lemon
+	"🔔",   // Bell
+	"🍀",   // Four-leaf clover
+	"💎",   // Diamond
+	"7️⃣", // Lucky 7
+	"🍇",   // Grapes
+	"🎰",   // Slot machine
+	"⭐",   // Star
+}
 
-	go func() {
-		icons := map[int]string{
-			0: "🍒",   // Cherries
-			1: "🍋",   // Lemon
-			2: "🔔",   // Bell
-			3: "🍀",   // Four-leaf clover
-			4: "💎",   // Diamond
-			5: "7️⃣", // Lucky 7
-			6: "🍇",   // Grapes
-			7: "🎰",   // Slot machine
-			8: "⭐",   // Star
-		}
-		var slots [3]int
-		for i := range len(slots) {
-			slots[i] = rand.Intn(9)
-		}
-		var output string
-		output += " | "
-		for _, slot := range slots {
-			output += icons[slot] + " | "
-		}
+// What a bet is multiplied by for three or two of an icon, indexed like
+// slotIcons. The bet itself is already gone, so 1 means money back
+var (
+	slotTriplePayout = []int64{5, 5, 10, 10, 25, 50, 5, 20, 15}
+	slotPairPayout   = []int64{1, 1, 2, 2, 3, 5, 1, 3, 2}
+)
 
-		ttsText := ""
+// The multiplier for a spin, zero when nothing matches
+func slotPayout(slots [3]int) int64 {
+	switch {
+	case slots[0] == slots[1] && slots[1] == slots[2]:
+		return slotTriplePayout[slots[0]]
+	case slots[0] == slots[1] || slots[0] == slots[2]:
+		return slotPairPayout[slots[0]]
+	case slots[1] == slots[2]:
+		return slotPairPayout[slots[1]]
+	}
+	return 0
+}
 
-		//Winner
-		if slots[0] == slots[1] && slots[1] == slots[2] {
-			discord.ChannelMessageSend(message.ChannelID, "You Won You Lucky Fuck\n")
+// !gamble spins for free, !gamble <amount> bets from the caller's wallet
+func slotMachine(cmd *CommandContext) {
+	bet := int64(cmd.Int("bet"))
+	if !placeBet(cmd, bet) {
+		return
+	}
 
-			botConnect(discord, message)
-			ttsText = "You Won You Lucky Fuck"
+	draws := newDraws(cmd.GuildID, "gamble")
+	var slots [3]int
+	for i := range len(slots) {
+		slots[i] = draws.Intn(len(slotIcons))
+	}
+	var output string
+	output += " | "
+	for _, slot := range slots {
+		output += slotIcons[slot] + " | "
+	}
 
-		} else {
-			discord.ChannelMessageSend(message.ChannelID, "You A Fuckin Lose Dummy\n")
+	// A bet wins when it pays back more than it cost, a free spin only on
+	// three of a kind like it always has
+	multiplier := slotPayout(slots)
+	won := multiplier > 1
+	if bet <= 0 {
+		won = slots[0] == slots[1] && slots[1] == slots[2]
+	}
+	draws.Log(fmt.Sprintf("%s bet %d on%s x%d", cmd.Author.ID, bet, output, multiplier))
 
-			botConnect(discord, message)
-			ttsText = "You A Fuckin Lose Dummy"
+	//Winner
+	if won {
+		cmd.Reply(winAnnouncement + "\n")
+	} else {
+		cmd.Reply(loseAnnouncement + "\n")
+	}
 
-		}
+	if line := settleBet(cmd.GuildID, cmd.Author.ID, bet, bet*multiplier, won); line != "" {
+		output += "\n" + line
+	}
 
-		discord.ChannelMessageSend(message.ChannelID, output)
-		sayHandler(discord, message, ttsText)
-	}()
+	cmd.Reply(output)
+	announce(cmd, won)
 }
 
-func handleImageMessage(discord *discordgo.Session, message *discordgo.MessageCreate) {
-	attachment := message.Attachments[0]
-	imageURL := attachment.URL
-	filename := attachment.Filename
-
-	imagePath, err := downloadAttachment(imageURL, filename)
+func handleImageMessage(cmd *CommandContext, attachment *discordgo.MessageAttachment) {
+	imagePath, err := downloadAttachment(attachment.URL, attachment.Filename, 0)
 	if err != nil {
-		discord.ChannelMessageSend(message.ChannelID, "Failed to download image.")
+		cmd.Reply("Failed to download image.")
 		return
 	}
 	defer os.Remove(imagePath) // cleanup
@@ -316,7 +380,7 @@ func handleImageMessage(discord *discordgo.Session, message *discordgo.MessageCr
 	ctx := context.Background()
 	response, err := imageProcess(ctx, imagePath, "")
 	if err != nil {
-		discord.ChannelMessageSend(message.ChannelID, "Gemini image processing failed: "+err.Error())
+		cmd.Reply("Gemini image processing failed: " + err.Error())
 		return
 	}
 
@@ -325,107 +389,95 @@ func handleImageMessage(discord *discordgo.Session, message *discordgo.MessageCr
 		response = response[:1997] + "..."
 	}
 
-	discord.ChannelMessageSend(message.ChannelID, response)
-	sayHandler(discord, message, response)
+	cmd.Reply(response)
+	sayHandler(cmd, response)
 }
 
-func askHandler(discord *discordgo.Session, message *discordgo.MessageCreate, guildID string) {
-	go func() {
-		discord.ChannelMessageSend(message.ChannelID, "🤖 Thinking...")
-		text := strings.TrimPrefix(message.Content, "!ask ")
+func askHandler(cmd *CommandContext, text string) {
+	cmd.Reply("🤖 Thinking...")
 
-		opID := fmt.Sprintf("gemini_%s_%d", guildID, time.Now().Unix())
-		ctx := createOperationContext(opID)
-		defer removeOperationContext(opID)
+	opID := fmt.Sprintf("gemini_%s_%d", cmd.GuildID, time.Now().Unix())
+	ctx := createOperationContext(opID)
+	defer removeOperationContext(opID)
 
-		log.Printf("Gemini prompt: %s", text)
+	log.Printf("Gemini prompt: %s", text)
 
-		reply, err := getGeminiResponse(ctx, text)
-		if err != nil {
-			if ctx.Err() != nil {
-				discord.ChannelMessageSend(message.ChannelID, "❌ Gemini operation cancelled.")
-			} else {
-				log.Printf("Gemini error: %v", err)
-				discord.ChannelMessageSend(message.ChannelID, "❌ Failed to get response from Gemini: "+err.Error())
-			}
-			return
+	reply, err := getGeminiResponse(ctx, text)
+	if err != nil {
+		if ctx.Err() != nil {
+			cmd.Reply("❌ Gemini operation cancelled.")
+		} else {
+			log.Printf("Gemini error: %v", err)
+			cmd.Reply("❌ Failed to get response from Gemini: " + err.Error())
 		}
+		return
+	}
 
-		log.Printf("Gemini response: %s", reply)
+	log.Printf("Gemini response: %s", reply)
 
-		if len(reply) > 2000 {
-			for len(reply) > 2000 {
-				discord.ChannelMessageSend(message.ChannelID, reply[:2000])
-				reply = reply[2000:]
-			}
+	if len(reply) > 2000 {
+		for len(reply) > 2000 {
+			cmd.Reply(reply[:2000])
+			reply = reply[2000:]
 		}
-		discord.ChannelMessageSend(message.ChannelID, reply)
-		fmt.Sprintf("gemini_output_%d_%d.mp3", time.Now().Unix(), rand.Intn(10000))
-		sayHandler(discord, message, reply)
-	}()
+	}
+	cmd.Reply(reply)
+	sayHandler(cmd, reply)
 }
 
-func imageGenerationHandler(discord *discordgo.Session, message *discordgo.MessageCreate, prompt string, guildID string) {
-	go func() {
-		discord.ChannelMessageSend(message.ChannelID, "🎨 Generating image for prompt: *"+prompt+"*...")
-
-		ctx := context.Background()
-		var imagePath string
-
-		// Check for image attachments
-		if len(message.Attachments) > 0 {
-
-			attachment := message.Attachments[0]
-
-			if strings.HasPrefix(attachment.ContentType, "image/") {
-
-				// Download image to a temp file
-				resp, err := http.Get(attachment.URL)
-				if err != nil {
-					discord.ChannelMessageSend(message.ChannelID, "❌ Failed to download attached image.")
-					return
-				}
-				defer resp.Body.Close()
-
-				tempFile, err := os.CreateTemp("", "discord_image_*.png")
-				if err != nil {
-					discord.ChannelMessageSend(message.ChannelID, "❌ Failed to create temporary image file.")
-					return
-				}
-				defer tempFile.Close()
-
-				_, err = io.Copy(tempFile, resp.Body)
-				if err != nil {
-					discord.ChannelMessageSend(message.ChannelID, "❌ Failed to save attached image.")
-					return
-				}
-
-				imagePath = tempFile.Name()
-				defer os.Remove(imagePath) // clean up local file
-			}
+func imageGenerationHandler(cmd *CommandContext, prompt string) {
+	cmd.Reply("🎨 Generating image for prompt: *" + prompt + "*...")
+
+	ctx := context.Background()
+	var imagePath string
+
+	// Check for an image attachment
+	if attachment := cmd.Attachment("image"); attachment != nil && strings.HasPrefix(attachment.ContentType, "image/") {
+		// Download image to a temp file
+		resp, err := http.Get(attachment.URL)
+		if err != nil {
+			cmd.Reply("❌ Failed to download attached image.")
+			return
 		}
+		defer resp.Body.Close()
 
-		// Call image generator
-		filename, err := generateImageFromPrompt(ctx, prompt, imagePath)
+		tempFile, err := os.CreateTemp("", "discord_image_*.png")
 		if err != nil {
-			discord.ChannelMessageSend(message.ChannelID, "❌ Failed to generate image: "+err.Error())
+			cmd.Reply("❌ Failed to create temporary image file.")
 			return
 		}
+		defer tempFile.Close()
 
-		file, err := os.Open(filename)
+		_, err = io.Copy(tempFile, resp.Body)
 		if err != nil {
-			discord.ChannelMessageSend(message.ChannelID, "❌ Failed to open generated image.")
+			cmd.Reply("❌ Failed to save attached image.")
 			return
 		}
-		defer file.Close()
 
-		discord.ChannelFileSend(message.ChannelID, filename, file)
+		imagePath = tempFile.Name()
+		defer os.Remove(imagePath) // clean up local file
+	}
 
-		// Clean up generated image after 30s
-		time.AfterFunc(30*time.Second, func() {
-			removeTempFile(guildID, filename)
-		})
-	}()
+	// Call image generator
+	filename, err := generateImageFromPrompt(ctx, prompt, imagePath)
+	if err != nil {
+		cmd.Reply("❌ Failed to generate image: " + err.Error())
+		return
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		cmd.Reply("❌ Failed to open generated image.")
+		return
+	}
+	defer file.Close()
+
+	cmd.ReplyFile(filename, file)
+
+	// Clean up generated image after 30s
+	time.AfterFunc(30*time.Second, func() {
+		removeTempFile(cmd.GuildID, filename)
+	})
 }
 
 func handleUserJoinedVoice(s *discordgo.Session, vsu *discordgo.VoiceStateUpdate, after *discordgo.VoiceState, userName string) {
@@ -433,45 +485,19 @@ func handleUserJoinedVoice(s *discordgo.Session, vsu *discordgo.VoiceStateUpdate
 
 	// Join voice channel
 	vc, err := s.ChannelVoiceJoin(vsu.GuildID, after.ChannelID, false, false)
-
-	// Create new voice session with context
-	ctx, cancel := context.WithCancel(context.Background())
-	session := &VoiceSession{
-		connection: vc,
-		ctx:        ctx,
-		cancel:     cancel,
-		isPlaying:  false,
-	}
-
-	botManager.mu.Lock()
-	botManager.voiceConnections[vsu.GuildID] = session
-	botManager.mu.Unlock()
-
 	if err != nil {
 		log.Printf("Error joining voice channel: %v", err)
 		return
 	}
 
-	// Create or get existing session for this guild
-	session, ok := sessions[vsu.GuildID]
-	if !ok {
-		ctx, cancel := context.WithCancel(context.Background())
-		session = &VoiceSession{
-			connection: vc,
-			ctx:        ctx,
-			cancel:     cancel,
-		}
-		sessions[vsu.GuildID] = session
-	} else {
-		// If a session exists, replace connection if necessary
-		session.connection = vc
-	}
+	// Reuse the guild's session so the welcome waits behind anything already queued
+	session := attachVoiceSession(s, vsu.GuildID, vc)
 
 	// Generate TTS text
 	ttsText := "Welcome to " + channelName + " " + userName
 
 	go func() {
-		filename := fmt.Sprintf("output_%d_%d.mp3", time.Now().Unix(), rand.Intn(10000))
+		filename := fmt.Sprintf("output_%d_%d.mp3", time.Now().Unix(), tempFileID())
 		opID := fmt.Sprintf("tts_gamble_%s_%d", vsu.GuildID, time.Now().Unix())
 		ctx2 := createOperationContext(opID)
 		defer removeOperationContext(opID)
@@ -483,47 +509,35 @@ func handleUserJoinedVoice(s *discordgo.Session, vsu *discordgo.VoiceStateUpdate
 			return
 		}
 
-		// Play audio with your function
-		playMP3(session, filename, s, getAnnouncementChannel(vsu.GuildID))
-
-		// Optional: clean up file after some time
-		time.AfterFunc(30*time.Second, func() {
-			os.Remove(filename)
-		})
+		// The queue removes the file once the welcome has played
+		addTempFile(vsu.GuildID, filename)
+		session.enqueue(newTrack(filename, getAnnouncementChannel(vsu.GuildID), ""))
 	}()
 
 }
 
-func handleTrackingCommands(discord *discordgo.Session, message *discordgo.MessageCreate) {
-	guildID := message.GuildID
-	userID := message.Author.ID
+func trackHandler(cmd *CommandContext) {
+	guildID := cmd.GuildID
 
-	switch {
-	case strings.Contains(message.Content, "!track me"):
-		if err := addTrackedUser(guildID, userID); err != nil {
-			discord.ChannelMessageSend(message.ChannelID, "❌ Failed to enable voice tracking: "+err.Error())
+	switch cmd.String("target") {
+	case "me":
+		if err := addTrackedUser(guildID, cmd.Author.ID); err != nil {
+			cmd.Reply("❌ Failed to enable voice tracking: " + err.Error())
 			return
 		}
-		discord.ChannelMessageSend(message.ChannelID, "✅ You will now be announced when joining/leaving voice channels!")
+		cmd.Reply("✅ You will now be announced when joining/leaving voice channels!")
 
-	case strings.Contains(message.Content, "!untrack me"):
-		if err := removeTrackedUser(guildID, userID); err != nil {
-			discord.ChannelMessageSend(message.ChannelID, "❌ Failed to disable voice tracking: "+err.Error())
-			return
-		}
-		discord.ChannelMessageSend(message.ChannelID, "❌ Voice announcements disabled for you.")
-
-	case strings.Contains(message.Content, "!tracked list"):
+	case "list":
 		// Admin command to see who's being tracked
 		users := getTrackedUsersForGuild(guildID)
 		if len(users) == 0 {
-			discord.ChannelMessageSend(message.ChannelID, "📋 No users are currently being tracked for voice announcements.")
+			cmd.Reply("📋 No users are currently being tracked for voice announcements.")
 			return
 		}
 
 		var userList string
 		for _, uid := range users {
-			userName := getUserDisplayName(discord, guildID, uid)
+			userName := getUserDisplayName(cmd.Discord, guildID, uid)
 			userList += fmt.Sprintf("• %s (`%s`)\n", userName, uid)
 		}
 
@@ -531,8 +545,16 @@ func handleTrackingCommands(discord *discordgo.Session, message *discordgo.Messa
 		if len(response) > 2000 {
 			response = response[:1997] + "..."
 		}
-		discord.ChannelMessageSend(message.ChannelID, response)
+		cmd.Reply(response)
+	}
+}
+
+func untrackHandler(cmd *CommandContext) {
+	if err := removeTrackedUser(cmd.GuildID, cmd.Author.ID); err != nil {
+		cmd.Reply("❌ Failed to disable voice tracking: " + err.Error())
+		return
 	}
+	cmd.Reply("❌ Voice announcements disabled for you.")
 }
 
 /*
@@ -542,12 +564,16 @@ func onVoiceStateUpdate(s *discordgo.Session, vsu *discordgo.VoiceStateUpdate) {
 
 	log.Printf("Voice state update: %v", vsu)
 
+	onRouletteVoiceJoin(s, vsu)
+
 	// Skip if user shouldn't be tracked
 	if !shouldTrackUser(s, vsu.UserID) {
 		log.Printf("Should Not Track User: %v", vsu)
 		return
 	}
 
+	recordVoiceSession(s, vsu)
+
 	// Skip if not configured to announce for this specific user
 	if !shouldAnnounceForUser(vsu.GuildID, vsu.UserID) {
 		log.Printf("Should Not Annouce User: %v", vsu)
@@ -576,158 +602,196 @@ func onVoiceStateUpdate(s *discordgo.Session, vsu *discordgo.VoiceStateUpdate) {
 	}
 }
 
-func newMessage(discord *discordgo.Session, message *discordgo.MessageCreate) {
-	if message.Author == nil || message.Author.ID == discord.State.User.ID {
-		return
-	}
+func killHandler(cmd *CommandContext) {
+	killGuildOperations(cmd.GuildID)
+	cmd.Reply("🛑 Killed all active operations for this server.")
+}
 
-	guildID := message.GuildID
+func disconnectHandler(cmd *CommandContext) {
+	botManager.mu.Lock()
+	defer botManager.mu.Unlock()
 
-	switch {
-	case strings.Contains(message.Content, "!help"):
-		commandList := "**🎮 Wang Bot Command List:**\n" +
-			"```" +
-			"💡 !help        → Show this command list\n" +
-			"💦 !cum         → Play a cursed custom sound\n" +
-			"🎵 !play        → Play an audio file (e.g., Heyooo.mp3, Lorenzofuckingdies.mp3)\n" +
-			"📺 !ytplay      → Play audio from a YouTube link\n" +
-			"🔌 !connect     → Connect the bot to a voice channel\n" +
-			"❌ !disconnect  → Disconnect the bot from the voice channel\n" +
-			"🧠 !ask         → Ask Gemini AI (supports text + Image Attachments)\n" +
-			"🗣️ !say         → Make the bot speak using text-to-speech\n" +
-			"🔀 !shuffle     → Shuffle users in voice channels randomly\n" +
-			"🎰 !gamble      → Spin the slot machine (big risk, big reward)\n" +
-			"📞 !recall      → Summon the whole squad to voice\n" +
-			"🛑 !kill        → Stop all current bot actions\n" +
-			"🔫 !shoot        → Wang Bot Shoots a Random User\n" +
-			"🎨 !create      → Ask Wang Bot To Create an Image (Image Attachments Supported)\n" +
-			"   !track me\n" +
-			"   !untrack me\n" +
-			"   !track list\n" +
-			"```"
-		discord.ChannelMessageSend(message.ChannelID, "Command List:\n"+commandList)
-
-	case strings.Contains(message.Content, "!kill"):
-		go func() {
-			killGuildOperations(guildID)
-			discord.ChannelMessageSend(message.ChannelID, "🛑 Killed all active operations for this server.")
-
-		}()
-
-	case strings.Contains(message.Content, "!shuffle"):
-		go func() {
-			shuffleVoiceChannels(discord, message)
-		}()
-
-	case strings.Contains(message.Content, "!cum"):
-		go func() {
-			botConnect(discord, message)
-			customMsg := &discordgo.MessageCreate{
-				Message: &discordgo.Message{
-					Content:   "!play Lorenzofuckingdies.mp3",
-					ChannelID: message.ChannelID,
-					GuildID:   message.GuildID,
-				},
-			}
-			soundPlay(discord, customMsg)
-		}()
-
-	case strings.HasPrefix(message.Content, "!play "):
-		go func() {
-			botConnect(discord, message)
-			soundPlay(discord, message)
-		}()
-
-	case strings.Contains(message.Content, "!connect"):
-		go func() {
-			botConnect(discord, message)
-			customMsg := &discordgo.MessageCreate{
-				Message: &discordgo.Message{
-					Content:   "!play Heyooo.mp3",
-					ChannelID: message.ChannelID,
-					GuildID:   message.GuildID,
-				},
-			}
-			soundPlay(discord, customMsg)
-		}()
-
-	case strings.Contains(message.Content, "!disconnect"):
-		botManager.mu.Lock()
-		if session, ok := botManager.voiceConnections[guildID]; ok && session != nil {
-			session.cancel()
-			if session.connection != nil {
-				session.connection.Disconnect()
-			}
-			delete(botManager.voiceConnections, guildID)
-			discord.ChannelMessageSend(message.ChannelID, "Good Bye 👋")
-		} else {
-			discord.ChannelMessageSend(message.ChannelID, "I'm not connected to a voice channel in this guild.")
-		}
-		botManager.mu.Unlock()
-
-	case strings.HasPrefix(message.Content, "!say "):
-		go func() {
-			trimmed := strings.TrimPrefix(message.Content, "!say ")
-			sayHandler(discord, message, trimmed)
-		}()
-
-	case strings.Contains(message.Content, "!see") && len(message.Attachments) > 0:
-		go func() {
-			handleImageMessage(discord, message)
-		}()
-
-	case strings.HasPrefix(message.Content, "!ask "):
-
-		// If !ask has an image case
-		if len(message.Attachments) > 0 {
-			go func() {
-				handleImageMessage(discord, message)
-			}()
-		} else {
-			go func() {
-				askHandler(discord, message, guildID)
-			}()
-		}
+	session, ok := botManager.voiceConnections[cmd.GuildID]
+	if !ok || session == nil {
+		cmd.Reply("I'm not connected to a voice channel in this guild.")
+		return
+	}
 
-	case strings.HasPrefix(message.Content, "!ytplay "):
-		go func() {
-			botConnect(discord, message)
-			url := strings.TrimSpace(strings.TrimPrefix(message.Content, "!ytplay "))
+	session.cancel()
+	if session.connection != nil {
+		session.connection.Disconnect()
+	}
+	delete(botManager.voiceConnections, cmd.GuildID)
+	cmd.Reply("Good Bye 👋")
+}
 
-			opID := fmt.Sprintf("youtube_%s_%d", guildID, time.Now().Unix())
-			ctx := createOperationContext(opID)
-			defer removeOperationContext(opID)
+func ytPlayHandler(cmd *CommandContext) {
+	if !botConnect(cmd) {
+		return
+	}
 
-			downloadAndPlayYT(ctx, discord, message.ChannelID, message.GuildID, url)
-		}()
+	opID := fmt.Sprintf("youtube_%s_%d", cmd.GuildID, time.Now().Unix())
+	ctx := createOperationContext(opID)
+	defer removeOperationContext(opID)
 
-	case strings.Contains(message.Content, "!gamble"):
-		go func() {
-			slotMachine(discord, message)
-		}()
+	playYouTube(ctx, cmd.Discord, cmd.ChannelID, cmd.GuildID, cmd.Author.ID, cmd.String("url"))
+}
 
-	case strings.Contains(message.Content, "!recall"):
-		go func() {
-			joinSameChannel(discord, message)
-		}()
+func askCommand(cmd *CommandContext) {
+	// An attached image goes to the vision model instead
+	if image := cmd.Attachment("image"); image != nil {
+		handleImageMessage(cmd, image)
+		return
+	}
+	if !cmd.Has("question") {
+		cmd.UsageError("ask a question or attach an image")
+		return
+	}
+	askHandler(cmd, cmd.String("question"))
+}
 
-	case strings.Contains(message.Content, "!shoot"):
-		go func() {
-			randomMoveSingle(discord, message)
-		}()
+func seeCommand(cmd *CommandContext) {
+	handleImageMessage(cmd, cmd.Attachment("image"))
+}
 
-	case strings.HasPrefix(message.Content, "!create "):
-		go func() {
-			trimmed := strings.TrimPrefix(message.Content, "!create ")
-			imageGenerationHandler(discord, message, trimmed, guildID)
-		}()
+// Every text command the bot understands, in the order !help lists them
+func registerCommands() {
+	for _, command := range []*Command{
+		{Name: "help", Aliases: []string{"commands"}, Category: categoryGeneral, Emoji: "💡", Description: "Show the command list or details for one command",
+			Args: []Arg{{Name: "command", Type: ArgString, Optional: true}}, Examples: []string{"", "seek"}, Run: helpHandler},
+		{Name: "config", Category: categoryGeneral, Emoji: "⚙️", Description: "Change the prefix, permissions, disabled commands and channel rules", GuildOnly: true, Level: PermAdmin,
+			Args: []Arg{
+				{Name: "setting", Type: ArgString, Optional: true, Choices: []string{"prefix", "mention", "disable", "enable", "channels", "level", "djrole", "modrole", "seed"}},
+				{Name: "args", Type: ArgText, Optional: true, Description: "New value, command name, channel rule or roles"},
+			},
+			ArgHelp:  "[prefix <prefix|reset> | mention on|off | disable <cmd> | enable <cmd> | channels [<cmd|*> allow|deny|clear #channels...] | level <cmd> <everyone|dj|mod|admin|owner|reset> | djrole|modrole <@roles...|clear> | seed <number|off>]",
+			Examples: []string{"", "prefix ?", "disable gamble", "channels * allow #bot-commands", "level shoot everyone", "djrole @Music"}, Run: configHandler},
+		{Name: "kill", Category: categoryGeneral, Emoji: "🛑", Description: "Stop all current bot actions", GuildOnly: true, Level: PermModerator, Run: killHandler},
+
+		{Name: "play", Category: categoryMusic, Emoji: "🎵", Description: "Play a sound by name or a file from the sounds folder", GuildOnly: true, NeedsVoice: true,
+			Args: []Arg{{Name: "sound", Type: ArgText}}, Examples: []string{"heyooo", "Heyooo.mp3"}, Run: soundPlay},
+		{Name: "ytplay", Category: categoryMusic, Emoji: "📺", Description: "Play audio from a YouTube link or playlist", GuildOnly: true, NeedsVoice: true,
+			Args: []Arg{{Name: "url", Type: ArgString}}, Examples: []string{"https://youtu.be/dQw4w9WgXcQ"}, Run: ytPlayHandler},
+		{Name: "ytsearch", Category: categoryMusic, Emoji: "🔎", Description: "Search YouTube, then !pick a result", GuildOnly: true,
+			Args: []Arg{{Name: "terms", Type: ArgText}}, Examples: []string{"never gonna give you up"}, RateLimit: RateLimit{User: BucketLimit{Burst: 1, RefillSeconds: 5}}, Run: ytSearchHandler},
+		{Name: "pick", Category: categoryMusic, Emoji: "👉", Description: "Queue a result from your last search", GuildOnly: true, NeedsVoice: true,
+			Args: []Arg{{Name: "number", Type: ArgInt}}, Examples: []string{"2"}, Run: ytPickHandler},
+		{Name: "queue", Aliases: []string{"q"}, Category: categoryMusic, Emoji: "📋", Description: "Show what's playing and what's up next", GuildOnly: true, Run: queueHandler},
+		{Name: "skip", Category: categoryMusic, Emoji: "⏭️", Description: "Skip the current track", GuildOnly: true, Run: skipHandler},
+		{Name: "clear", Category: categoryMusic, Emoji: "🧹", Description: "Clear all upcoming tracks", GuildOnly: true, Run: clearQueueHandler},
+		{Name: "remove", Category: categoryMusic, Emoji: "🗑️", Description: "Remove a track from the queue", GuildOnly: true,
+			Args: []Arg{{Name: "position", Type: ArgInt}}, Examples: []string{"3"}, Run: removeFromQueueHandler},
+		{Name: "pause", Category: categoryMusic, Emoji: "⏸️", Description: "Pause the current track", GuildOnly: true, Run: pauseHandler},
+		{Name: "resume", Category: categoryMusic, Emoji: "▶️", Description: "Resume a paused track", GuildOnly: true, Run: resumeHandler},
+		{Name: "seek", Category: categoryMusic, Emoji: "⏩", Description: "Jump to a position in the current track", GuildOnly: true,
+			Args: []Arg{{Name: "position", Type: ArgDuration}}, Examples: []string{"1:30", "1:02:03"}, Run: seekHandler},
+		{Name: "volume", Aliases: []string{"vol"}, Category: categoryMusic, Emoji: "🔊", Description: "Set the volume or toggle loudness normalization", GuildOnly: true,
+			Args:     []Arg{{Name: "level", Type: ArgString, Optional: true}, {Name: "state", Type: ArgString, Optional: true}},
+			ArgHelp:  fmt.Sprintf("[0-%d | normalize on|off]", maxVolume),
+			Examples: []string{"", "80", "normalize on"}, Run: volumeHandler},
+		{Name: "loop", Category: categoryMusic, Emoji: "🔁", Description: "Loop the current track or the whole queue", GuildOnly: true,
+			Args:     []Arg{{Name: "mode", Type: ArgString, Optional: true, Choices: []string{loopTrack, loopQueue, loopOff}}},
+			Examples: []string{"track", "off"}, Run: loopHandler},
+		{Name: "fx", Category: categoryMusic, Emoji: "🎛️", Description: "Apply an audio effect preset", GuildOnly: true,
+			Args: []Arg{{Name: "effect", Type: ArgString, Optional: true}}, Examples: []string{"", "nightcore", "off"}, Run: effectsHandler},
+
+		{Name: "sb", Aliases: []string{"soundboard"}, Category: categorySoundboard, Emoji: "🔈", Description: "Play, list or add soundboard sounds", GuildOnly: true,
+			Args:     []Arg{{Name: "args", Type: ArgText, Optional: true, Description: "Sound name, list [tag] or add <name> [tags...]"}, {Name: "file", Type: ArgAttachment, Optional: true, Description: "Audio file for add"}},
+			ArgHelp:  "<name> | list [tag] | add <name> [tags...]",
+			Examples: []string{"heyooo", "list", "add airhorn loud meme"}, Run: soundboardHandler},
+		{Name: "cum", Category: categorySoundboard, Emoji: "💦", Description: "Play a cursed custom sound", GuildOnly: true, NeedsVoice: true,
+			Run: func(cmd *CommandContext) { playSound(cmd, "lorenzo") }},
+		{Name: "connect", Category: categorySoundboard, Emoji: "🔌", Description: "Connect the bot to your voice channel", GuildOnly: true, NeedsVoice: true,
+			Run: func(cmd *CommandContext) { playSound(cmd, "heyooo") }},
+
+		{Name: "disconnect", Category: categoryVoice, Emoji: "❌", Description: "Disconnect the bot from voice", GuildOnly: true, Level: PermDJ, Run: disconnectHandler},
+		{Name: "shuffle", Category: categoryVoice, Emoji: "🔀", Description: "Shuffle users in voice channels randomly", GuildOnly: true, Level: PermModerator, RateLimit: RateLimit{User: BucketLimit{Burst: 1, RefillSeconds: 10}},
+			Run: shuffleVoiceChannels},
+		{Name: "recall", Category: categoryVoice, Emoji: "📞", Description: "Summon the whole squad to your voice channel, or another one", GuildOnly: true, Level: PermModerator, RateLimit: RateLimit{User: BucketLimit{Burst: 1, RefillSeconds: 10}},
+			Args: []Arg{{Name: "channel", Type: ArgChannel, Optional: true, Description: "Voice channel to gather everyone in"}}, Examples: []string{"", "#general"}, Run: joinSameChannel},
+		{Name: "shoot", Category: categoryVoice, Emoji: "🔫", Description: "Shoot a random user in your voice channel, or someone specific", GuildOnly: true, NeedsVoice: true, Level: PermModerator, RateLimit: RateLimit{User: BucketLimit{Burst: 1, RefillSeconds: 10}},
+			Args: []Arg{{Name: "target", Type: ArgUser, Optional: true, Description: "Who to shoot"}}, Examples: []string{"", "@someone"}, Run: randomMoveSingle},
+		{Name: "undo", Category: categoryVoice, Emoji: "↩️", Description: "Move back everyone the last shuffle, recall, shoot or teams moved", GuildOnly: true, Level: PermModerator,
+			Run: undoHandler},
+		{Name: "teams", Category: categoryVoice, Emoji: "🎲", Description: "Split your voice channel into random teams, optionally moving each team", GuildOnly: true, NeedsVoice: true, Level: PermModerator, RateLimit: RateLimit{User: BucketLimit{Burst: 1, RefillSeconds: 5}},
+			Args: []Arg{
+				{Name: "action", Type: ArgString, Description: "Number of teams, reroll or return"},
+				{Name: "channels", Type: ArgText, Optional: true, Description: "One voice channel per team to move them to"},
+			},
+			ArgHelp: "<n [#channels...] | reroll | return>", Examples: []string{"2", "2 #team-a #team-b", "reroll", "return"}, Run: teamsHandler},
+		{Name: "protect", Category: categoryVoice, Emoji: "🛡️", Description: "Opt out of shuffle, shoot and recall, or protect voice channels", GuildOnly: true,
+			Args:    []Arg{{Name: "target", Type: ArgText, Optional: true, Description: "me, or voice channels (moderators)"}},
+			ArgHelp: "[me | #channels...]", Examples: []string{"", "me", "#afk-lounge"}, Run: protectHandler},
+		{Name: "unprotect", Category: categoryVoice, Emoji: "🎯", Description: "Opt back in to being moved, or unprotect voice channels", GuildOnly: true,
+			Args:    []Arg{{Name: "target", Type: ArgText, Description: "me, or voice channels (moderators)"}},
+			ArgHelp: "<me | #channels...>", Examples: []string{"me", "#afk-lounge"}, Run: protectHandler},
+		{Name: "track", Aliases: []string{"tracked"}, Category: categoryVoice, Emoji: "📣", Description: "Announce yourself in voice, or list who is announced", GuildOnly: true,
+			Args: []Arg{{Name: "target", Type: ArgString, Choices: []string{"me", "list"}}}, Examples: []string{"me", "list"}, Run: trackHandler},
+		{Name: "untrack", Category: categoryVoice, Emoji: "🔕", Description: "Stop announcing yourself in voice", GuildOnly: true,
+			Args: []Arg{{Name: "target", Type: ArgString, Choices: []string{"me"}}}, Examples: []string{"me"}, Run: untrackHandler},
+
+		{Name: "ask", Category: categoryAI, Emoji: "🧠", Description: "Ask Gemini AI, with an optional image attachment", GuildOnly: true,
+			Args:     []Arg{{Name: "question", Type: ArgText, Optional: true}, {Name: "image", Type: ArgAttachment, Optional: true, Description: "Image to ask about"}},
+			Examples: []string{"why is the sky blue?"}, RateLimit: paidRateLimit, Run: askCommand},
+		{Name: "see", Category: categoryAI, Emoji: "👀", Description: "Describe an attached image", GuildOnly: true, RateLimit: paidRateLimit,
+			Args: []Arg{{Name: "image", Type: ArgAttachment, Description: "Image to describe"}}, Run: seeCommand},
+		{Name: "say", Category: categoryAI, Emoji: "🗣️", Description: "Make the bot speak using text-to-speech", GuildOnly: true, NeedsVoice: true,
+			Args: []Arg{{Name: "text", Type: ArgText}}, Examples: []string{"hello everyone"}, RateLimit: ttsRateLimit,
+			Run: func(cmd *CommandContext) { sayHandler(cmd, cmd.String("text")) }},
+		{Name: "create", Category: categoryAI, Emoji: "🎨", Description: "Generate an image, optionally from an attachment", GuildOnly: true,
+			Args:     []Arg{{Name: "prompt", Type: ArgText}, {Name: "image", Type: ArgAttachment, Optional: true, Description: "Image to start from"}},
+			Examples: []string{"a cat wearing a crown"}, RateLimit: imageRateLimit,
+			Run: func(cmd *CommandContext) { imageGenerationHandler(cmd, cmd.String("prompt")) }},
+
+		{Name: "roulette", Category: categoryFun, Emoji: "🔫", Description: "Russian roulette in voice, the loser gets moved or muted", GuildOnly: true,
+			Args: []Arg{
+				{Name: "action", Type: ArgString, Choices: []string{"start", "join", "leave", "begin", "cancel", "stats"}},
+				{Name: "user", Type: ArgUser, Optional: true, Description: "Whose stats to show"},
+			},
+			Examples: []string{"start", "join", "begin", "stats", "stats @someone"}, Run: rouletteHandler},
+		{Name: "pull", Category: categoryFun, Emoji: "😰", Description: "Pull the trigger when it's your turn in roulette", GuildOnly: true, Run: pullHandler},
+		{Name: "leaderboard", Aliases: []string{"lb", "top"}, Category: categoryFun, Emoji: "🏆", Description: "Top players and users in this server", GuildOnly: true,
+			Args: []Arg{
+				{Name: "board", Type: ArgString, Optional: true, Choices: leaderboardNames},
+				{Name: "view", Type: ArgString, Optional: true, Choices: []string{viewAllTime, viewWeekly}},
+			},
+			Examples: []string{"", "roulette", "voice weekly"}, Run: leaderboardHandler},
+		{Name: "gamble", Category: categoryFun, Emoji: "🎰", Description: "Spin the slot machine, optionally betting coins", GuildOnly: true, RateLimit: ttsRateLimit,
+			Args: []Arg{{Name: "bet", Type: ArgInt, Optional: true, Description: "Coins to bet"}}, Examples: []string{"", "50"}, Run: slotMachine},
+		{Name: "blackjack", Aliases: []string{"bj"}, Category: categoryFun, Emoji: "🃏", Description: "Play blackjack against the dealer", GuildOnly: true, RateLimit: ttsRateLimit,
+			Args: []Arg{{Name: "bet", Type: ArgInt, Optional: true, Description: "Coins to bet"}}, Examples: []string{"", "100"}, Run: blackjackHandler},
+		{Name: "coinflip", Aliases: []string{"flip"}, Category: categoryFun, Emoji: "🪙", Description: "Call a coin flip, double or nothing", GuildOnly: true, RateLimit: ttsRateLimit,
+			Args: []Arg{
+				{Name: "call", Type: ArgString, Choices: []string{games.Heads, games.Tails}},
+				{Name: "bet", Type: ArgInt, Optional: true, Description: "Coins to bet"},
+			},
+			Examples: []string{"heads", "tails 50"}, Run: coinFlipHandler},
+		{Name: "dice", Aliases: []string{"duel"}, Category: categoryFun, Emoji: "🎲", Description: "Challenge someone to a dice duel, the higher roll takes the pot", GuildOnly: true, RateLimit: ttsRateLimit,
+			Args:     []Arg{{Name: "user", Type: ArgUser, Description: "Who to challenge"}, {Name: "bet", Type: ArgInt, Optional: true, Description: "Coins each player stakes"}},
+			Examples: []string{"@someone", "@someone 100"}, Run: diceHandler},
+		{Name: "higherlower", Aliases: []string{"hl"}, Category: categoryFun, Emoji: "🔼", Description: "Guess whether the next card is higher or lower", GuildOnly: true, RateLimit: ttsRateLimit,
+			Args: []Arg{{Name: "bet", Type: ArgInt, Optional: true, Description: "Coins to bet"}}, Examples: []string{"", "50"}, Run: higherLowerHandler},
+		{Name: "daily", Category: categoryFun, Emoji: "💰", Description: "Claim your daily coins", GuildOnly: true, Run: dailyHandler},
+		{Name: "balance", Aliases: []string{"bal", "wallet"}, Category: categoryFun, Emoji: "👛", Description: "Show your wallet, or someone else's", GuildOnly: true,
+			Args: []Arg{{Name: "user", Type: ArgUser, Optional: true}}, Examples: []string{"", "@someone"}, Run: balanceHandler},
+		{Name: "give", Aliases: []string{"pay"}, Category: categoryFun, Emoji: "🤝", Description: "Give someone coins from your wallet", GuildOnly: true, RateLimit: RateLimit{User: BucketLimit{Burst: 1, RefillSeconds: 5}},
+			Args: []Arg{{Name: "user", Type: ArgUser}, {Name: "amount", Type: ArgInt}}, Examples: []string{"@someone 100"}, Run: giveHandler},
+		{Name: "ledger", Category: categoryFun, Emoji: "📒", Description: "Audit the coin ledger or reverse a transaction", GuildOnly: true, Level: PermAdmin,
+			Args:    []Arg{{Name: "args", Type: ArgText, Optional: true, Description: "A user, or reverse and a transaction number"}},
+			ArgHelp: "[@user | reverse <id>]", Examples: []string{"", "@someone", "reverse 42"}, Run: ledgerHandler},
+	} {
+		registerCommand(command)
+	}
+}
 
-	case strings.Contains(message.Content, "!track me"):
-		handleTrackingCommands(discord, message)
-	case strings.Contains(message.Content, "!untrack me"):
-		handleTrackingCommands(discord, message)
-	case strings.Contains(message.Content, "!tracked list"):
-		handleTrackingCommands(discord, message)
+func init() {
+	registerCommands()
+}
 
+func newMessage(discord *discordgo.Session, message *discordgo.MessageCreate) {
+	if message.Author == nil || message.Author.ID == discord.State.User.ID {
+		return
 	}
+
+	dispatchCommand(discord, message)
 }
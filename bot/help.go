@@ -0,0 +1,194 @@
+package bot
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"strconv"
+	"strings"
+)
+
+const (
+	categoryGeneral    = "General"
+	categoryMusic      = "Music"
+	categorySoundboard = "Soundboard"
+	categoryVoice      = "Voice"
+	categoryAI         = "AI"
+	categoryFun        = "Fun"
+
+	helpPageSize   = 10
+	helpColor      = 0x5865F2
+	helpPagePrefix = "help:page:"
+)
+
+// Order the !help pages appear in
+var helpCategories = []string{categoryGeneral, categoryMusic, categorySoundboard, categoryVoice, categoryAI, categoryFun}
+
+// One page of the !help listing
+type helpPage struct {
+	category string
+	commands []*Command
+}
+
+// Group the commands the caller can use into pages, one category per page
+// and at most helpPageSize commands each
+func helpPages(discord *discordgo.Session, guildID, channelID, userID string) []helpPage {
+	level := memberLevel(discord, guildID, channelID, userID)
+
+	byCategory := make(map[string][]*Command)
+	for _, command := range commandList {
+		if command.availableIn(guildID) && commandBlocked(command, guildID, channelID) == "" && commandLevel(command, guildID) <= level {
+			byCategory[command.Category] = append(byCategory[command.Category], command)
+		}
+	}
+
+	var pages []helpPage
+	for _, category := range helpCategories {
+		commands := byCategory[category]
+		for len(commands) > 0 {
+			n := min(len(commands), helpPageSize)
+			pages = append(pages, helpPage{category: category, commands: commands[:n]})
+			commands = commands[n:]
+		}
+	}
+	return pages
+}
+
+func helpPageEmbed(pages []helpPage, index int, prefix string) *discordgo.MessageEmbed {
+	page := pages[index]
+
+	var lines []string
+	for _, command := range page.commands {
+		lines = append(lines, fmt.Sprintf("%s `%s` → %s", command.Emoji, command.Usage(prefix), command.Description))
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       "🎮 Wang Bot Commands — " + page.category,
+		Description: strings.Join(lines, "\n"),
+		Color:       helpColor,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Page %d/%d • %shelp <command> for details", index+1, len(pages), prefix),
+		},
+	}
+}
+
+// Previous/next buttons, the custom ID carries the page they lead to
+func helpPageButtons(pages []helpPage, index int) []discordgo.MessageComponent {
+	if len(pages) < 2 {
+		return []discordgo.MessageComponent{}
+	}
+
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{Emoji: &discordgo.ComponentEmoji{Name: "◀️"}, Style: discordgo.SecondaryButton,
+					CustomID: helpPagePrefix + strconv.Itoa(index-1), Disabled: index == 0},
+				discordgo.Button{Emoji: &discordgo.ComponentEmoji{Name: "▶️"}, Style: discordgo.SecondaryButton,
+					CustomID: helpPagePrefix + strconv.Itoa(index+1), Disabled: index == len(pages)-1},
+			},
+		},
+	}
+}
+
+// Everything there is to know about one command
+func commandDetailEmbed(command *Command, guildID, prefix string) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("%s %s%s", command.Emoji, prefix, command.Name),
+		Description: command.Description,
+		Color:       helpColor,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Usage", Value: "`" + command.Usage(prefix) + "`"},
+		},
+	}
+
+	if len(command.Aliases) > 0 {
+		aliases := make([]string, len(command.Aliases))
+		for i, alias := range command.Aliases {
+			aliases[i] = "`" + prefix + alias + "`"
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Aliases", Value: strings.Join(aliases, ", "), Inline: true})
+	}
+
+	if limit := command.rateLimit(); limit.User.enabled() || limit.Guild.enabled() {
+		var limits []string
+		if limit.User.enabled() {
+			limits = append(limits, "Per user: "+limit.User.String())
+		}
+		if limit.Guild.enabled() {
+			limits = append(limits, "Per server: "+limit.Guild.String())
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Rate limit", Value: strings.Join(limits, "\n"), Inline: true})
+	}
+
+	var notes []string
+	if level := commandLevel(command, guildID); level != PermEveryone {
+		notes = append(notes, "Permission level: "+level.String())
+	}
+	if command.GuildOnly {
+		notes = append(notes, "Server only")
+	}
+	if command.NeedsVoice {
+		notes = append(notes, "You must be in a voice channel")
+	}
+	if len(notes) > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Requires", Value: strings.Join(notes, "\n"), Inline: true})
+	}
+
+	if len(command.Examples) > 0 {
+		examples := make([]string, len(command.Examples))
+		for i, example := range command.Examples {
+			examples[i] = "`" + strings.TrimSpace(prefix+command.Name+" "+example) + "`"
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Examples", Value: strings.Join(examples, "\n")})
+	}
+
+	embed.Footer = &discordgo.MessageEmbedFooter{Text: "Category: " + command.Category}
+	return embed
+}
+
+func helpHandler(cmd *CommandContext) {
+	// Listings always show the text prefix, slash users can read it as /
+	prefix := guildPrefix(cmd.GuildID)
+
+	if cmd.Has("command") {
+		command, ok := lookupCommand(strings.TrimPrefix(cmd.String("command"), prefix))
+		if !ok || !command.availableIn(cmd.GuildID) {
+			cmd.Replyf("❌ Unknown command **%s**. Try %shelp for the full list.", cmd.String("command"), prefix)
+			return
+		}
+		cmd.ReplyEmbed(commandDetailEmbed(command, cmd.GuildID, prefix), nil)
+		return
+	}
+
+	pages := helpPages(cmd.Discord, cmd.GuildID, cmd.ChannelID, cmd.Author.ID)
+	if len(pages) == 0 {
+		cmd.Reply("📭 No commands are available here.")
+		return
+	}
+	cmd.ReplyEmbed(helpPageEmbed(pages, 0, prefix), helpPageButtons(pages, 0))
+}
+
+// Flip the !help message to the page named in the button's custom ID
+func handleHelpButton(discord *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	index, err := strconv.Atoi(strings.TrimPrefix(interaction.MessageComponentData().CustomID, helpPagePrefix))
+	user := interaction.User
+	if interaction.Member != nil {
+		user = interaction.Member.User
+	}
+	pages := helpPages(discord, interaction.GuildID, interaction.ChannelID, user.ID)
+	if err != nil || index < 0 || index >= len(pages) {
+		respondEphemeral(discord, interaction, "❌ That help page no longer exists.")
+		return
+	}
+
+	err = discord.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{helpPageEmbed(pages, index, guildPrefix(interaction.GuildID))},
+			Components: helpPageButtons(pages, index),
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to update help message: %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package bot
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"strings"
+)
+
+/*
+Entry point for button clicks and other interactions
+*/
+func onInteractionCreate(discord *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	switch interaction.Type {
+	case discordgo.InteractionApplicationCommand:
+		handleSlashCommand(discord, interaction)
+
+	case discordgo.InteractionMessageComponent:
+		customID := interaction.MessageComponentData().CustomID
+
+		switch {
+		case strings.HasPrefix(customID, "np:"):
+			handleNowPlayingButton(discord, interaction)
+		case strings.HasPrefix(customID, "help:"):
+			handleHelpButton(discord, interaction)
+		case strings.HasPrefix(customID, leaderboardPrefix):
+			handleLeaderboardButton(discord, interaction)
+		case strings.HasPrefix(customID, casinoPrefix):
+			handleCasinoButton(discord, interaction)
+		}
+	}
+}
+
+// Reply to an interaction with a message only the clicking user can see
+func respondEphemeral(discord *discordgo.Session, interaction *discordgo.InteractionCreate, content string) {
+	err := discord.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to respond to interaction: %v", err)
+	}
+}
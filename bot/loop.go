@@ -0,0 +1,41 @@
+package bot
+
+const (
+	loopOff   = "off"
+	loopTrack = "track"
+	loopQueue = "queue"
+)
+
+func (vs *VoiceSession) loopMode() string {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	return vs.loop
+}
+
+func (vs *VoiceSession) setLoopMode(mode string) {
+	vs.mu.Lock()
+	vs.loop = mode
+	vs.mu.Unlock()
+}
+
+func loopHandler(cmd *CommandContext) {
+	session, ok := getVoiceSession(cmd.GuildID)
+	if !ok {
+		cmd.Reply("❌ Bot is not connected to a voice channel.")
+		return
+	}
+
+	switch cmd.String("mode") {
+	case "":
+		cmd.Replyf("🔁 Loop mode is **%s**.", session.loopMode())
+	case loopTrack:
+		session.setLoopMode(loopTrack)
+		cmd.Reply("🔂 Looping the current track.")
+	case loopQueue:
+		session.setLoopMode(loopQueue)
+		cmd.Reply("🔁 Looping the whole queue.")
+	case loopOff:
+		session.setLoopMode(loopOff)
+		cmd.Reply("➡️ Looping disabled.")
+	}
+}
@@ -0,0 +1,279 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	nowPlayingInterval = 10 * time.Second
+	progressBarWidth   = 16
+	volumeStep         = 10
+
+	nowPlayingPause   = "np:pause"
+	nowPlayingSkip    = "np:skip"
+	nowPlayingStop    = "np:stop"
+	nowPlayingVolDown = "np:voldown"
+	nowPlayingVolUp   = "np:volup"
+)
+
+// What the now-playing embed shows, copied under the session lock
+type nowPlayingState struct {
+	track    Track
+	position time.Duration
+	paused   bool
+	loop     string
+	finished bool
+}
+
+func (vs *VoiceSession) setCurrentDuration(duration time.Duration) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	if vs.current != nil && vs.current.Duration == 0 {
+		vs.current.Duration = duration
+	}
+}
+
+func (vs *VoiceSession) nowPlayingState() (nowPlayingState, bool) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	if vs.current == nil {
+		return nowPlayingState{}, false
+	}
+	return nowPlayingState{
+		track:    *vs.current,
+		position: vs.position,
+		paused:   vs.paused,
+		loop:     vs.loop,
+	}, true
+}
+
+// Post the now-playing message for a track and keep it updated until the
+// track's context ends, then strip the buttons from it
+func startNowPlaying(ctx context.Context, session *VoiceSession, track *Track) {
+	discord := session.discord
+
+	state, ok := session.nowPlayingState()
+	if !ok {
+		return
+	}
+
+	msg, err := discord.ChannelMessageSendComplex(track.ChannelID, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{nowPlayingEmbed(session.guildID, state)},
+		Components: nowPlayingButtons(state.paused),
+	})
+	if err != nil {
+		log.Printf("Failed to send now-playing message: %v", err)
+		return
+	}
+
+	session.mu.Lock()
+	session.nowPlaying = msg
+	session.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(nowPlayingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if current, ok := session.nowPlayingState(); ok {
+					state = current
+					editNowPlaying(discord, msg, session.guildID, state)
+				}
+			case <-ctx.Done():
+				state.finished = true
+				editNowPlaying(discord, msg, session.guildID, state)
+
+				session.mu.Lock()
+				if session.nowPlaying == msg {
+					session.nowPlaying = nil
+				}
+				session.mu.Unlock()
+				return
+			}
+		}
+	}()
+}
+
+func editNowPlaying(discord *discordgo.Session, msg *discordgo.Message, guildID string, state nowPlayingState) {
+	components := nowPlayingButtons(state.paused)
+	if state.finished {
+		components = []discordgo.MessageComponent{}
+	}
+
+	embeds := []*discordgo.MessageEmbed{nowPlayingEmbed(guildID, state)}
+	_, err := discord.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:         msg.ID,
+		Channel:    msg.ChannelID,
+		Embeds:     &embeds,
+		Components: &components,
+	})
+	if err != nil {
+		log.Printf("Failed to update now-playing message: %v", err)
+	}
+}
+
+func nowPlayingEmbed(guildID string, state nowPlayingState) *discordgo.MessageEmbed {
+	track := state.track
+
+	status := "🎵 Now Playing"
+	switch {
+	case state.finished:
+		status = "✅ Finished"
+	case state.paused:
+		status = "⏸️ Paused"
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:  track.Title,
+		URL:    track.URL,
+		Color:  0xFF0000,
+		Author: &discordgo.MessageEmbedAuthor{Name: status},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("🔊 Volume %d%%", getAudioSettings(guildID).Volume),
+		},
+	}
+
+	if state.loop != loopOff {
+		embed.Footer.Text += " • 🔁 Loop " + state.loop
+	}
+
+	if !state.finished {
+		embed.Description = progressBar(state.position, track.Duration)
+	}
+
+	if track.Uploader != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Uploader", Value: track.Uploader, Inline: true})
+	}
+	if track.Duration > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Duration", Value: formatTimestamp(track.Duration), Inline: true})
+	}
+	if track.Requester != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Requested by", Value: "<@" + track.Requester + ">", Inline: true})
+	}
+	if track.Thumbnail != "" {
+		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: track.Thumbnail}
+	}
+	return embed
+}
+
+// Text progress bar like "▬▬▬🔘▬▬▬▬ 01:23 / 03:45"
+func progressBar(position, duration time.Duration) string {
+	if duration <= 0 {
+		return fmt.Sprintf("`%s`", formatTimestamp(position))
+	}
+
+	filled := int(float64(position) / float64(duration) * progressBarWidth)
+	filled = max(0, min(filled, progressBarWidth-1))
+
+	bar := strings.Repeat("▬", filled) + "🔘" + strings.Repeat("▬", progressBarWidth-1-filled)
+	return fmt.Sprintf("%s `%s / %s`", bar, formatTimestamp(position), formatTimestamp(duration))
+}
+
+func nowPlayingButtons(paused bool) []discordgo.MessageComponent {
+	pause := discordgo.Button{Label: "Pause", Emoji: &discordgo.ComponentEmoji{Name: "⏸️"}, Style: discordgo.SecondaryButton, CustomID: nowPlayingPause}
+	if paused {
+		pause = discordgo.Button{Label: "Resume", Emoji: &discordgo.ComponentEmoji{Name: "▶️"}, Style: discordgo.SuccessButton, CustomID: nowPlayingPause}
+	}
+
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				pause,
+				discordgo.Button{Label: "Skip", Emoji: &discordgo.ComponentEmoji{Name: "⏭️"}, Style: discordgo.PrimaryButton, CustomID: nowPlayingSkip},
+				discordgo.Button{Label: "Stop", Emoji: &discordgo.ComponentEmoji{Name: "⏹️"}, Style: discordgo.DangerButton, CustomID: nowPlayingStop},
+				discordgo.Button{Emoji: &discordgo.ComponentEmoji{Name: "🔉"}, Style: discordgo.SecondaryButton, CustomID: nowPlayingVolDown},
+				discordgo.Button{Emoji: &discordgo.ComponentEmoji{Name: "🔊"}, Style: discordgo.SecondaryButton, CustomID: nowPlayingVolUp},
+			},
+		},
+	}
+}
+
+// The commands each now-playing button stands for, stop both clears the
+// queue and skips
+var nowPlayingCommands = map[string][]string{
+	nowPlayingPause:   {"pause"},
+	nowPlayingSkip:    {"skip"},
+	nowPlayingStop:    {"clear", "skip"},
+	nowPlayingVolDown: {"volume"},
+	nowPlayingVolUp:   {"volume"},
+}
+
+// Handle a click on one of the now-playing buttons
+func handleNowPlayingButton(discord *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	session, ok := getVoiceSession(interaction.GuildID)
+
+	var active bool
+	if ok {
+		session.mu.RLock()
+		active = session.nowPlaying != nil && session.nowPlaying.ID == interaction.Message.ID
+		session.mu.RUnlock()
+	}
+	if !active {
+		respondEphemeral(discord, interaction, "❌ This player is no longer active.")
+		return
+	}
+
+	// Each button runs under the rules of the command it stands for
+	customID := interaction.MessageComponentData().CustomID
+	if interaction.Member == nil || interaction.Member.User == nil {
+		respondEphemeral(discord, interaction, "❌ This only works in a server.")
+		return
+	}
+	for _, name := range nowPlayingCommands[customID] {
+		if reason := commandDenied(discord, interaction.GuildID, interaction.ChannelID, interaction.Member.User, name); reason != "" {
+			respondEphemeral(discord, interaction, reason)
+			return
+		}
+	}
+
+	var err error
+	switch customID {
+	case nowPlayingPause:
+		if err = session.pause(); err != nil {
+			err = session.resume()
+		}
+	case nowPlayingSkip:
+		_, _ = session.skipCurrent()
+	case nowPlayingStop:
+		session.stopPlayback()
+	case nowPlayingVolDown, nowPlayingVolUp:
+		step := volumeStep
+		if customID == nowPlayingVolDown {
+			step = -volumeStep
+		}
+		err = updateAudioSettings(interaction.GuildID, func(s *AudioSettings) {
+			s.Volume = max(0, min(s.Volume+step, maxVolume))
+		})
+	}
+
+	if err != nil {
+		respondEphemeral(discord, interaction, "❌ "+err.Error())
+		return
+	}
+
+	// Redraw the same message with the new state
+	state, ok := session.nowPlayingState()
+	if !ok {
+		respondEphemeral(discord, interaction, "⏹️ Playback stopped.")
+		return
+	}
+	err = discord.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{nowPlayingEmbed(interaction.GuildID, state)},
+			Components: nowPlayingButtons(state.paused),
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to update now-playing message: %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+package bot
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"slices"
+	"strings"
+)
+
+// PermissionLevel is how trusted a member is, each level includes the ones below it
+type PermissionLevel int
+
+const (
+	PermEveryone PermissionLevel = iota
+	PermDJ
+	PermModerator
+	PermAdmin
+	PermOwner
+)
+
+// Role name that grants DJ without any configuration
+const djRoleName = "dj"
+
+// Guild permissions that make someone a moderator
+const moderatorPermissions = discordgo.PermissionVoiceMoveMembers | discordgo.PermissionKickMembers | discordgo.PermissionModerateMembers
+
+var permissionLevelNames = map[PermissionLevel]string{
+	PermEveryone:  "everyone",
+	PermDJ:        "dj",
+	PermModerator: "mod",
+	PermAdmin:     "admin",
+	PermOwner:     "owner",
+}
+
+func (l PermissionLevel) String() string {
+	return permissionLevelNames[l]
+}
+
+func parsePermissionLevel(name string) (PermissionLevel, bool) {
+	name = strings.ToLower(name)
+	if name == "moderator" {
+		name = "mod"
+	}
+	for level, levelName := range permissionLevelNames {
+		if levelName == name {
+			return level, true
+		}
+	}
+	return PermEveryone, false
+}
+
+// The level a command needs in a guild, per-guild overrides win. !config
+// stays admin-only so it can't be locked away or handed out
+func commandLevel(command *Command, guildID string) PermissionLevel {
+	if command.Name == "config" {
+		return command.Level
+	}
+	if name, ok := getGuildSettings(guildID).CommandLevels[command.Name]; ok {
+		if level, ok := parsePermissionLevel(name); ok {
+			return level
+		}
+	}
+	return command.Level
+}
+
+func isBotOwner(userID string) bool {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return slices.Contains(botConfig.Owners, userID)
+}
+
+// Work out a member's level from the bot owner list, their guild
+// permissions and their roles
+func memberLevel(discord *discordgo.Session, guildID, channelID, userID string) PermissionLevel {
+	if isBotOwner(userID) {
+		return PermOwner
+	}
+	if guildID == "" {
+		return PermEveryone
+	}
+
+	perms, err := discord.State.UserChannelPermissions(userID, channelID)
+	if err != nil {
+		perms, err = discord.UserChannelPermissions(userID, channelID)
+		if err != nil {
+			log.Printf("Failed to get permissions for %s in %s: %v", userID, channelID, err)
+			perms = 0
+		}
+	}
+
+	if perms&(discordgo.PermissionAdministrator|discordgo.PermissionManageGuild) != 0 {
+		return PermAdmin
+	}
+
+	member, err := discord.State.Member(guildID, userID)
+	if err != nil {
+		member, err = discord.GuildMember(guildID, userID)
+	}
+	var roles []string
+	if err == nil {
+		roles = member.Roles
+	}
+
+	settings := getGuildSettings(guildID)
+	hasRole := func(ids []string) bool {
+		for _, role := range roles {
+			if slices.Contains(ids, role) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if perms&moderatorPermissions != 0 || hasRole(settings.ModRoles) {
+		return PermModerator
+	}
+
+	if hasRole(settings.DJRoles) {
+		return PermDJ
+	}
+	for _, roleID := range roles {
+		if role, err := discord.State.Role(guildID, roleID); err == nil && strings.EqualFold(role.Name, djRoleName) {
+			return PermDJ
+		}
+	}
+	return PermEveryone
+}
+
+// Whether a member may run a command, denied attempts are logged
+func checkPermission(cmd *CommandContext) bool {
+	required := commandLevel(cmd.Command, cmd.GuildID)
+	if required == PermEveryone {
+		return true
+	}
+
+	level := memberLevel(cmd.Discord, cmd.GuildID, cmd.ChannelID, cmd.Author.ID)
+	if level >= required {
+		return true
+	}
+
+	log.Printf("Denied %s to %s (%s) in guild %s: needs %s, has %s",
+		cmd.Command.Name, cmd.Author.Username, cmd.Author.ID, cmd.GuildID, required, level)
+	return false
+}
+
+// Why a member can't use a command reached without a message, like through
+// a button, empty when they can. Runs the same checks as a typed command
+func commandDenied(discord *discordgo.Session, guildID, channelID string, user *discordgo.User, name string) string {
+	command, ok := lookupCommand(name)
+	if !ok {
+		return ""
+	}
+	prefix := guildPrefix(guildID)
+	if reason := commandBlocked(command, guildID, channelID); reason != "" {
+		return fmt.Sprintf("🚫 %s%s %s.", prefix, command.Name, reason)
+	}
+
+	required := commandLevel(command, guildID)
+	if required == PermEveryone {
+		return ""
+	}
+	if level := memberLevel(discord, guildID, channelID, user.ID); level < required {
+		log.Printf("Denied %s button to %s (%s) in guild %s: needs %s, has %s",
+			command.Name, user.Username, user.ID, guildID, required, level)
+		return fmt.Sprintf("🔒 %s%s needs the **%s** permission level.", prefix, command.Name, required)
+	}
+	return ""
+}
+
+// !config level <command> <level> and !config djrole/modrole <@role...|clear>
+func configPermissions(cmd *CommandContext, setting string, args []string) error {
+	guildID := cmd.GuildID
+
+	if setting == "level" {
+		if len(args) != 2 {
+			cmd.UsageError("level needs a command and everyone, dj, mod, admin, owner or reset")
+			return nil
+		}
+		name, ok := configCommandName(args[0])
+		if !ok {
+			cmd.Replyf("❌ Unknown command **%s**.", args[0])
+			return nil
+		}
+		if name == "config" {
+			cmd.Reply("❌ The config command always needs admin.")
+			return nil
+		}
+
+		reset := strings.EqualFold(args[1], "reset")
+		level, ok := parsePermissionLevel(args[1])
+		if !ok && !reset {
+			cmd.UsageError("level must be everyone, dj, mod, admin, owner or reset")
+			return nil
+		}
+
+		err := updateGuildSettings(guildID, func(s *GuildSettings) {
+			if reset {
+				delete(s.CommandLevels, name)
+				return
+			}
+			if s.CommandLevels == nil {
+				s.CommandLevels = make(map[string]string)
+			}
+			s.CommandLevels[name] = level.String()
+		})
+		if err == nil {
+			command, _ := lookupCommand(name)
+			cmd.Replyf("🔐 %s%s now needs **%s**.", guildPrefix(guildID), name, commandLevel(command, guildID))
+		}
+		return err
+	}
+
+	if len(args) == 0 {
+		cmd.UsageError(setting + " needs role mentions or clear")
+		return nil
+	}
+
+	var roleIDs []string
+	if !(len(args) == 1 && strings.EqualFold(args[0], "clear")) {
+		for _, word := range args {
+			id, ok := mentionID(word, "@&")
+			if !ok {
+				cmd.Replyf("❌ %s isn't a role.", word)
+				return nil
+			}
+			roleIDs = append(roleIDs, id)
+		}
+	}
+
+	err := updateGuildSettings(guildID, func(s *GuildSettings) {
+		if setting == "djrole" {
+			s.DJRoles = roleIDs
+		} else {
+			s.ModRoles = roleIDs
+		}
+	})
+	if err == nil && setting == "djrole" {
+		cmd.Reply("🔐 DJ roles: " + roleMentions(roleIDs))
+	} else if err == nil {
+		cmd.Reply("🔐 Moderator roles: " + roleMentions(roleIDs))
+	}
+	return err
+}
+
+func roleMentions(ids []string) string {
+	if len(ids) == 0 {
+		return "none"
+	}
+	mentions := make([]string, len(ids))
+	for i, id := range ids {
+		mentions[i] = fmt.Sprintf("<@&%s>", id)
+	}
+	return strings.Join(mentions, ", ")
+}
@@ -0,0 +1,155 @@
+package bot
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"slices"
+	"strings"
+)
+
+// A member a moving command left alone, and why
+type skippedMove struct {
+	userID string
+	reason string
+}
+
+// Why a member in a voice channel must not be moved, empty when they can be.
+// The guild's AFK channel is always protected
+func moveProtection(discord *discordgo.Session, guildID, userID, channelID string) string {
+	settings := getGuildSettings(guildID)
+	if slices.Contains(settings.ProtectedUsers, userID) {
+		return "opted out"
+	}
+	if channelProtected(discord, guildID, channelID) {
+		return "in a protected channel"
+	}
+	return ""
+}
+
+// Whether members in a channel are left alone, and nobody gets moved into it
+func channelProtected(discord *discordgo.Session, guildID, channelID string) bool {
+	if slices.Contains(getGuildSettings(guildID).ProtectedChannels, channelID) {
+		return true
+	}
+	guild, err := discord.State.Guild(guildID)
+	return err == nil && guild.AfkChannelID != "" && guild.AfkChannelID == channelID
+}
+
+// Drop protected channels from a list of move destinations
+func unprotectedChannels(discord *discordgo.Session, guildID string, channelIDs []string) []string {
+	return slices.DeleteFunc(slices.Clone(channelIDs), func(id string) bool {
+		return channelProtected(discord, guildID, id)
+	})
+}
+
+// Split voice states into the members that may be moved and the ones skipped
+func filterMovable(discord *discordgo.Session, guildID string, states []*discordgo.VoiceState) ([]*discordgo.VoiceState, []skippedMove) {
+	var movable []*discordgo.VoiceState
+	var skipped []skippedMove
+	for _, vs := range states {
+		if reason := moveProtection(discord, guildID, vs.UserID, vs.ChannelID); reason != "" {
+			skipped = append(skipped, skippedMove{userID: vs.UserID, reason: reason})
+			continue
+		}
+		movable = append(movable, vs)
+	}
+	return movable, skipped
+}
+
+// A line for command replies naming who was skipped, empty when nobody was
+func skippedSummary(skipped []skippedMove) string {
+	if len(skipped) == 0 {
+		return ""
+	}
+	parts := make([]string, len(skipped))
+	for i, s := range skipped {
+		parts[i] = fmt.Sprintf("<@%s> (%s)", s.userID, s.reason)
+	}
+	return "\n🛡️ Skipped: " + strings.Join(parts, ", ")
+}
+
+func protectionSummary(guildID string) string {
+	settings := getGuildSettings(guildID)
+
+	users := "none"
+	if len(settings.ProtectedUsers) > 0 {
+		mentions := make([]string, len(settings.ProtectedUsers))
+		for i, id := range settings.ProtectedUsers {
+			mentions[i] = "<@" + id + ">"
+		}
+		users = strings.Join(mentions, ", ")
+	}
+
+	channels := "none"
+	if len(settings.ProtectedChannels) > 0 {
+		channels = channelMentions(settings.ProtectedChannels)
+	}
+
+	return fmt.Sprintf("🛡️ **Protected from moves**\nMembers: %s\nChannels: %s (plus the AFK channel)", users, channels)
+}
+
+// !protect / !unprotect with "me" or voice channel mentions, no argument
+// lists what is protected. Channels need the moderator level
+func protectHandler(cmd *CommandContext) {
+	guildID := cmd.GuildID
+	protect := cmd.Command.Name == "protect"
+	words := strings.Fields(cmd.String("target"))
+
+	if len(words) == 0 {
+		cmd.Reply(protectionSummary(guildID))
+		return
+	}
+
+	if len(words) == 1 && strings.EqualFold(words[0], "me") {
+		userID := cmd.Author.ID
+		err := updateGuildSettings(guildID, func(s *GuildSettings) {
+			s.ProtectedUsers = slices.DeleteFunc(s.ProtectedUsers, func(id string) bool { return id == userID })
+			if protect {
+				s.ProtectedUsers = append(s.ProtectedUsers, userID)
+			}
+		})
+		switch {
+		case err != nil:
+			cmd.Reply("❌ Failed to save settings: " + err.Error())
+		case protect:
+			cmd.Reply("🛡️ You won't be moved by shuffle, shoot or recall anymore.")
+		default:
+			cmd.Reply("🎯 You're fair game again.")
+		}
+		return
+	}
+
+	if memberLevel(cmd.Discord, guildID, cmd.ChannelID, cmd.Author.ID) < PermModerator {
+		cmd.Replyf("🔒 Protecting channels needs the **%s** permission level.", PermModerator)
+		return
+	}
+
+	var channelIDs []string
+	for _, word := range words {
+		id, ok := mentionID(word, "#")
+		if !ok {
+			cmd.UsageError(word + " isn't me or a channel")
+			return
+		}
+		channel, err := cmd.Discord.State.Channel(id)
+		if err != nil || channel.GuildID != guildID || channel.Type != discordgo.ChannelTypeGuildVoice {
+			cmd.Replyf("❌ %s isn't a voice channel in this server.", word)
+			return
+		}
+		channelIDs = append(channelIDs, id)
+	}
+
+	err := updateGuildSettings(guildID, func(s *GuildSettings) {
+		s.ProtectedChannels = slices.DeleteFunc(s.ProtectedChannels, func(id string) bool {
+			return slices.Contains(channelIDs, id)
+		})
+		if protect {
+			s.ProtectedChannels = append(s.ProtectedChannels, channelIDs...)
+		}
+	})
+	if err != nil {
+		cmd.Reply("❌ Failed to save settings: " + err.Error())
+		return
+	}
+	cmd.Reply(protectionSummary(guildID))
+}
@@ -0,0 +1,374 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// Track is a single playback request waiting in a guild's queue
+type Track struct {
+	Title     string
+	Filename  string
+	URL       string // source page for YouTube tracks
+	Stream    bool   // pipe URL through yt-dlp instead of reading Filename
+	ChannelID string // text channel that receives playback errors
+	Requester string // user ID, empty for bot-initiated sounds
+
+	// Metadata from yt-dlp, empty for local files
+	Uploader  string
+	Duration  time.Duration
+	Thumbnail string
+
+	VolumeOffsetDB float64 // per-sound gain from the soundboard index
+}
+
+func newTrack(filename, channelID, requester string) *Track {
+	return &Track{
+		Title:     filepath.Base(filename),
+		Filename:  filename,
+		ChannelID: channelID,
+		Requester: requester,
+	}
+}
+
+// Create a voice session for the connection and start its queue worker
+func newVoiceSession(discord *discordgo.Session, guildID string, vc *discordgo.VoiceConnection) *VoiceSession {
+	ctx, cancel := context.WithCancel(context.Background())
+	session := &VoiceSession{
+		connection: vc,
+		discord:    discord,
+		guildID:    guildID,
+		ctx:        ctx,
+		cancel:     cancel,
+		isPlaying:  false,
+		wake:       make(chan struct{}, 1),
+		loop:       loopOff,
+		seekTo:     make(chan time.Duration, 1),
+	}
+	go session.run()
+	return session
+}
+
+// Register a voice connection for the guild, keeping the existing session
+// (and its queue) when the bot only moved to another channel
+func attachVoiceSession(discord *discordgo.Session, guildID string, vc *discordgo.VoiceConnection) *VoiceSession {
+	botManager.mu.Lock()
+	defer botManager.mu.Unlock()
+
+	if session, ok := botManager.voiceConnections[guildID]; ok && session != nil && session.ctx.Err() == nil {
+		session.mu.Lock()
+		session.connection = vc
+		session.mu.Unlock()
+		return session
+	}
+
+	session := newVoiceSession(discord, guildID, vc)
+	botManager.voiceConnections[guildID] = session
+	return session
+}
+
+func getVoiceSession(guildID string) (*VoiceSession, bool) {
+	botManager.mu.RLock()
+	session, ok := botManager.voiceConnections[guildID]
+	botManager.mu.RUnlock()
+
+	if !ok || session == nil {
+		return nil, false
+	}
+
+	session.mu.RLock()
+	connected := session.connection != nil
+	session.mu.RUnlock()
+	if !connected {
+		return nil, false
+	}
+	return session, true
+}
+
+// Add a track to the end of the queue, returns its 1-based position
+func (vs *VoiceSession) enqueue(track *Track) int {
+	vs.mu.Lock()
+	vs.queue = append(vs.queue, track)
+	position := len(vs.queue)
+	vs.mu.Unlock()
+
+	// Non-blocking: one pending wake-up is enough for the worker
+	select {
+	case vs.wake <- struct{}{}:
+	default:
+	}
+	return position
+}
+
+// Worker goroutine, drains the queue in order until the session is cancelled
+func (vs *VoiceSession) run() {
+	defer vs.clearQueue()
+
+	for {
+		track, ctx, ok := vs.next()
+		if !ok {
+			return
+		}
+
+		playTrack(ctx, vs, track)
+		vs.finish(track)
+	}
+}
+
+// Block until a track is available, then mark it as the current one
+func (vs *VoiceSession) next() (*Track, context.Context, bool) {
+	for {
+		vs.mu.Lock()
+		if len(vs.queue) > 0 {
+			track := vs.queue[0]
+			vs.queue = vs.queue[1:]
+
+			ctx, cancel := context.WithCancel(vs.ctx)
+			vs.current = track
+			vs.skip = cancel
+			vs.isPlaying = true
+			vs.paused = false
+			vs.position = 0
+			vs.mu.Unlock()
+
+			// Drop a seek that was aimed at the previous track
+			select {
+			case <-vs.seekTo:
+			default:
+			}
+			return track, ctx, true
+		}
+		vs.mu.Unlock()
+
+		select {
+		case <-vs.wake:
+		case <-vs.ctx.Done():
+			return nil, nil, false
+		}
+	}
+}
+
+// Wrap up the current track, putting it back in the queue when a loop mode
+// wants it again. Looped tracks keep their files until they finally leave
+func (vs *VoiceSession) finish(track *Track) {
+	vs.mu.Lock()
+	if vs.skip != nil {
+		vs.skip()
+		vs.skip = nil
+	}
+
+	// Tracks that never produced audio aren't looped, or a broken file would spin forever
+	played := vs.position > 0
+	requeued := false
+	if played && vs.ctx.Err() == nil && !vs.stopped {
+		switch vs.loop {
+		case loopTrack:
+			if !vs.skipped {
+				vs.queue = append([]*Track{track}, vs.queue...)
+				requeued = true
+			}
+		case loopQueue:
+			vs.queue = append(vs.queue, track)
+			requeued = true
+		}
+	}
+
+	vs.current = nil
+	vs.isPlaying = false
+	vs.paused = false
+	vs.skipped = false
+	vs.stopped = false
+	vs.mu.Unlock()
+
+	if !requeued {
+		releaseTrack(vs.guildID, track)
+	}
+}
+
+// Stop the current track, the worker moves on to the next one.
+// In queue loop mode the skipped track stays in the rotation
+func (vs *VoiceSession) skipCurrent() (*Track, bool) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	if vs.current == nil || vs.skip == nil {
+		return nil, false
+	}
+	vs.skipped = true
+	vs.skip()
+	return vs.current, true
+}
+
+// Clear the queue and end the current track without looping it
+func (vs *VoiceSession) stopPlayback() {
+	vs.clearQueue()
+
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	if vs.current != nil && vs.skip != nil {
+		vs.stopped = true
+		vs.skip()
+	}
+}
+
+// Drop every pending track, returns how many were removed
+func (vs *VoiceSession) clearQueue() int {
+	vs.mu.Lock()
+	dropped := vs.queue
+	vs.queue = nil
+	vs.mu.Unlock()
+
+	for _, track := range dropped {
+		releaseTrack(vs.guildID, track)
+	}
+	return len(dropped)
+}
+
+// Remove the pending track at the given 1-based position
+func (vs *VoiceSession) removeAt(position int) (*Track, error) {
+	vs.mu.Lock()
+	if position < 1 || position > len(vs.queue) {
+		size := len(vs.queue)
+		vs.mu.Unlock()
+		return nil, fmt.Errorf("position must be between 1 and %d", size)
+	}
+	track := vs.queue[position-1]
+	vs.queue = append(vs.queue[:position-1], vs.queue[position:]...)
+	vs.mu.Unlock()
+
+	releaseTrack(vs.guildID, track)
+	return track, nil
+}
+
+// Snapshot of the current track and the pending queue. The tracks are
+// copies, the worker fills in durations under vs.mu as they start
+func (vs *VoiceSession) snapshot() (*Track, []*Track) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	var current *Track
+	if vs.current != nil {
+		track := *vs.current
+		current = &track
+	}
+	pending := make([]*Track, len(vs.queue))
+	for i, queued := range vs.queue {
+		track := *queued
+		pending[i] = &track
+	}
+	return current, pending
+}
+
+// Temp files (TTS clips, downloads) are deleted once their track leaves the queue
+func releaseTrack(guildID string, track *Track) {
+	if track.Filename != "" && isTempFile(guildID, track.Filename) {
+		removeTempFile(guildID, track.Filename)
+	}
+}
+
+// Queue a file for playback and tell the channel where it landed
+func enqueueFile(discord *discordgo.Session, session *VoiceSession, filename, channelID, requester string) {
+	enqueueTrack(discord, session, newTrack(filename, channelID, requester))
+}
+
+func enqueueTrack(discord *discordgo.Session, session *VoiceSession, track *Track) {
+	channelID := track.ChannelID
+	busy := session.isBusy()
+	position := session.enqueue(track)
+
+	if busy || position > 1 {
+		discord.ChannelMessageSend(channelID, fmt.Sprintf("📥 Queued **%s** at position %d.", track.Title, position))
+	}
+}
+
+// " (mm:ss)" when the duration is known
+func (t *Track) durationSuffix() string {
+	if t.Duration <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", formatTimestamp(t.Duration))
+}
+
+func (vs *VoiceSession) isBusy() bool {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	return vs.current != nil
+}
+
+func queueHandler(cmd *CommandContext) {
+	session, ok := getVoiceSession(cmd.GuildID)
+	if !ok {
+		cmd.Reply("❌ Bot is not connected to a voice channel.")
+		return
+	}
+
+	current, pending := session.snapshot()
+	if current == nil && len(pending) == 0 {
+		cmd.Reply("📭 The queue is empty.")
+		return
+	}
+
+	var response string
+	if mode := session.loopMode(); mode != loopOff {
+		response += fmt.Sprintf("🔁 **Loop:** %s\n", mode)
+	}
+	if current != nil {
+		response += fmt.Sprintf("🎵 **Now playing:** %s `[%s]`\n", current.Title, formatTimestamp(session.currentPosition()))
+	}
+	if len(pending) > 0 {
+		response += fmt.Sprintf("📋 **Up next (%d):**\n", len(pending))
+		for i, track := range pending {
+			response += fmt.Sprintf("`%d.` %s%s\n", i+1, track.Title, track.durationSuffix())
+		}
+	}
+
+	response = truncateLines(response, 2000)
+	cmd.Reply(response)
+}
+
+func skipHandler(cmd *CommandContext) {
+	session, ok := getVoiceSession(cmd.GuildID)
+	if !ok {
+		cmd.Reply("❌ Bot is not connected to a voice channel.")
+		return
+	}
+
+	track, ok := session.skipCurrent()
+	if !ok {
+		cmd.Reply("❌ Nothing is playing right now.")
+		return
+	}
+	cmd.Replyf("⏭️ Skipped **%s**.", track.Title)
+}
+
+func clearQueueHandler(cmd *CommandContext) {
+	session, ok := getVoiceSession(cmd.GuildID)
+	if !ok {
+		cmd.Reply("❌ Bot is not connected to a voice channel.")
+		return
+	}
+
+	dropped := session.clearQueue()
+	cmd.Replyf("🧹 Cleared %d track(s) from the queue.", dropped)
+}
+
+func removeFromQueueHandler(cmd *CommandContext) {
+	session, ok := getVoiceSession(cmd.GuildID)
+	if !ok {
+		cmd.Reply("❌ Bot is not connected to a voice channel.")
+		return
+	}
+
+	track, err := session.removeAt(cmd.Int("position"))
+	if err != nil {
+		cmd.Reply("❌ " + err.Error())
+		return
+	}
+	log.Printf("Removed %s from queue in guild %s", track.Filename, cmd.GuildID)
+	cmd.Replyf("🗑️ Removed **%s** from the queue.", track.Title)
+}
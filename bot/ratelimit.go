@@ -0,0 +1,272 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often full buckets are dropped and the state file is written
+const rateLimitSweepInterval = time.Minute
+
+// Default limits for commands that cost money on every use, the Gemini
+// APIs behind !ask/!see/!create and the TTS call behind !say and !gamble
+var (
+	paidRateLimit = RateLimit{
+		User:  BucketLimit{Burst: 3, RefillSeconds: 20},
+		Guild: BucketLimit{Burst: 10, RefillSeconds: 10},
+	}
+	imageRateLimit = RateLimit{
+		User:  BucketLimit{Burst: 2, RefillSeconds: 60},
+		Guild: BucketLimit{Burst: 5, RefillSeconds: 60},
+	}
+	ttsRateLimit = RateLimit{
+		User:  BucketLimit{Burst: 3, RefillSeconds: 10},
+		Guild: BucketLimit{Burst: 10, RefillSeconds: 5},
+	}
+)
+
+// tokenBucket holds up to a limit's burst of tokens, one is spent per use
+// and they refill continuously. Exported fields so the state can be saved
+type tokenBucket struct {
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+}
+
+var (
+	// Buckets keyed "user:<guildID>:<userID>:<command>" and "guild:<guildID>:<command>"
+	rateBuckets   = make(map[string]*tokenBucket)
+	rateBucketsMu sync.Mutex
+)
+
+func (l BucketLimit) enabled() bool {
+	return l.Burst > 0 && l.RefillSeconds > 0
+}
+
+func (l RateLimit) enabled() bool {
+	return l.User.enabled() || l.Guild.enabled()
+}
+
+func (l BucketLimit) refill() time.Duration {
+	return time.Duration(l.RefillSeconds * float64(time.Second))
+}
+
+func (l BucketLimit) String() string {
+	return fmt.Sprintf("%d, +1 every %s", l.Burst, l.refill())
+}
+
+// Top the bucket up for the time passed since it was last touched
+func (b *tokenBucket) refresh(limit BucketLimit, now time.Time) {
+	elapsed := now.Sub(b.Updated).Seconds()
+	b.Tokens = math.Min(float64(limit.Burst), b.Tokens+elapsed/limit.RefillSeconds)
+	b.Updated = now
+}
+
+// How long until the bucket has a whole token
+func (b *tokenBucket) wait(limit BucketLimit) time.Duration {
+	if b.Tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.Tokens) * limit.RefillSeconds * float64(time.Second))
+}
+
+// Callers must hold rateBucketsMu
+func rateBucket(key string, limit BucketLimit, now time.Time) *tokenBucket {
+	bucket, ok := rateBuckets[key]
+	if !ok {
+		bucket = &tokenBucket{Tokens: float64(limit.Burst), Updated: now}
+		rateBuckets[key] = bucket
+	}
+	bucket.refresh(limit, now)
+	return bucket
+}
+
+// The limits a command runs under: the config entry, then the command's own
+// RateLimit
+func (c *Command) rateLimit() RateLimit {
+	configMu.RLock()
+	limit, ok := botConfig.RateLimits.Commands[c.Name]
+	configMu.RUnlock()
+	if ok {
+		return limit
+	}
+
+	return c.RateLimit
+}
+
+// Spend a token from the caller's and the guild's bucket for the command.
+// When either is empty nothing is spent and the wait until both have a
+// token again is returned
+func takeRateLimit(command *Command, guildID, userID string) time.Duration {
+	limit := command.rateLimit()
+	if !limit.enabled() {
+		return 0
+	}
+
+	rateBucketsMu.Lock()
+	defer rateBucketsMu.Unlock()
+
+	now := time.Now()
+	var buckets []*tokenBucket
+	var wait time.Duration
+
+	if limit.User.enabled() {
+		bucket := rateBucket(fmt.Sprintf("user:%s:%s:%s", guildID, userID, command.Name), limit.User, now)
+		buckets = append(buckets, bucket)
+		wait = max(wait, bucket.wait(limit.User))
+	}
+	if limit.Guild.enabled() && guildID != "" {
+		bucket := rateBucket(fmt.Sprintf("guild:%s:%s", guildID, command.Name), limit.Guild, now)
+		buckets = append(buckets, bucket)
+		wait = max(wait, bucket.wait(limit.Guild))
+	}
+
+	if wait > 0 {
+		return wait
+	}
+	for _, bucket := range buckets {
+		bucket.Tokens--
+	}
+	return 0
+}
+
+// The lowest permission level that skips rate limits, admin unless configured
+func rateLimitBypassLevel() PermissionLevel {
+	configMu.RLock()
+	name := botConfig.RateLimits.BypassLevel
+	configMu.RUnlock()
+
+	if level, ok := parsePermissionLevel(name); ok {
+		return level
+	}
+	return PermAdmin
+}
+
+// Whether the command may run now, replying with the wait when it can't.
+// Members at the bypass level are never limited and spend no tokens
+func checkRateLimit(cmd *CommandContext) bool {
+	if !cmd.Command.rateLimit().enabled() {
+		return true
+	}
+	if memberLevel(cmd.Discord, cmd.GuildID, cmd.ChannelID, cmd.Author.ID) >= rateLimitBypassLevel() {
+		return true
+	}
+
+	wait := takeRateLimit(cmd.Command, cmd.GuildID, cmd.Author.ID)
+	if wait <= 0 {
+		return true
+	}
+
+	cmd.Replyf("⏳ Slow down, try %s%s again in %ds.", cmd.Prefix, cmd.Command.Name, int(math.Ceil(wait.Seconds())))
+	return false
+}
+
+// Forget buckets that have refilled completely, they behave exactly like
+// missing ones
+func sweepRateBuckets() {
+	rateBucketsMu.Lock()
+	defer rateBucketsMu.Unlock()
+
+	now := time.Now()
+	for key, bucket := range rateBuckets {
+		command, ok := lookupCommand(key[strings.LastIndex(key, ":")+1:])
+		if !ok {
+			delete(rateBuckets, key)
+			continue
+		}
+
+		limit := command.rateLimit().User
+		if strings.HasPrefix(key, "guild:") {
+			limit = command.rateLimit().Guild
+		}
+		if !limit.enabled() {
+			delete(rateBuckets, key)
+			continue
+		}
+
+		bucket.refresh(limit, now)
+		if bucket.Tokens >= float64(limit.Burst) {
+			delete(rateBuckets, key)
+		}
+	}
+}
+
+func rateLimitStateFile() string {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return botConfig.RateLimits.StateFile
+}
+
+// Restore saved buckets so restarting the bot doesn't reset every limit
+func loadRateLimits() error {
+	path := rateLimitStateFile()
+	if path == "" {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open rate limit state: %w", err)
+	}
+	defer file.Close()
+
+	buckets := make(map[string]*tokenBucket)
+	if err := json.NewDecoder(file).Decode(&buckets); err != nil {
+		return fmt.Errorf("failed to decode rate limit state: %w", err)
+	}
+
+	rateBucketsMu.Lock()
+	rateBuckets = buckets
+	rateBucketsMu.Unlock()
+
+	log.Printf("Loaded %d rate limit bucket(s)", len(buckets))
+	return nil
+}
+
+func saveRateLimits() error {
+	path := rateLimitStateFile()
+	if path == "" {
+		return nil
+	}
+
+	rateBucketsMu.Lock()
+	defer rateBucketsMu.Unlock()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create rate limit state: %w", err)
+	}
+	defer file.Close()
+
+	if err := json.NewEncoder(file).Encode(rateBuckets); err != nil {
+		return fmt.Errorf("failed to encode rate limit state: %w", err)
+	}
+	return nil
+}
+
+// Periodically drop full buckets and write the state file
+func runRateLimitSweeper(stop <-chan struct{}) {
+	ticker := time.NewTicker(rateLimitSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			sweepRateBuckets()
+			if err := saveRateLimits(); err != nil {
+				log.Printf("Failed to save rate limits: %v", err)
+			}
+		}
+	}
+}
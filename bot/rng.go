@@ -0,0 +1,102 @@
+package bot
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Where seeds come from. The default reads crypto/rand, tests swap in a
+// fixed seed to replay outcomes
+var seedSource = cryptoSeed
+
+var (
+	// Operations run per guild since its seed override was set, so each
+	// one gets its own seed and a restart replays the same sequence
+	seedCounters   = make(map[string]int64)
+	seedCountersMu sync.Mutex
+
+	// Temp file names only need to be unique, so they share one source
+	tempRand   = rand.New(rand.NewSource(cryptoSeed()))
+	tempRandMu sync.Mutex
+)
+
+func cryptoSeed() int64 {
+	var b [8]byte
+	if _, err := cryptorand.Read(b[:]); err != nil {
+		log.Printf("Failed to read a crypto seed, falling back to the clock: %v", err)
+		return time.Now().UnixNano()
+	}
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}
+
+// The seed for the next random operation in a guild. A guild's seed
+// override makes the sequence reproducible for debugging
+func nextSeed(guildID string) int64 {
+	override := getGuildSettings(guildID).RandomSeed
+	if override == nil {
+		return seedSource()
+	}
+
+	seedCountersMu.Lock()
+	defer seedCountersMu.Unlock()
+	n := seedCounters[guildID]
+	seedCounters[guildID]++
+	return *override + n
+}
+
+// Start a guild's override sequence over, after the override changes
+func resetSeedCounter(guildID string) {
+	seedCountersMu.Lock()
+	defer seedCountersMu.Unlock()
+	delete(seedCounters, guildID)
+}
+
+// Draws is the randomness for one operation. It remembers its seed and
+// every value drawn so the outcome can be logged and checked later
+type Draws struct {
+	rng     *rand.Rand
+	seed    int64
+	guildID string
+	purpose string
+	values  []string
+}
+
+// Randomness for one operation, like a slot spin or a shuffle
+func newDraws(guildID, purpose string) *Draws {
+	seed := nextSeed(guildID)
+	return &Draws{
+		rng:     rand.New(rand.NewSource(seed)),
+		seed:    seed,
+		guildID: guildID,
+		purpose: purpose,
+	}
+}
+
+func (d *Draws) Intn(n int) int {
+	v := d.rng.Intn(n)
+	d.values = append(d.values, fmt.Sprintf("%d/%d", v, n))
+	return v
+}
+
+func (d *Draws) Shuffle(n int, swap func(i, j int)) {
+	d.rng.Shuffle(n, swap)
+	d.values = append(d.values, fmt.Sprintf("shuffle(%d)", n))
+}
+
+// Log the seed, the draws and how they turned out
+func (d *Draws) Log(outcome string) {
+	log.Printf("RNG %s in guild %s: seed %d, draws [%s], %s", d.purpose, d.guildID, d.seed, strings.Join(d.values, " "), outcome)
+}
+
+// A number for making temp file names unique
+func tempFileID() int {
+	tempRandMu.Lock()
+	defer tempRandMu.Unlock()
+	return tempRand.Intn(100000)
+}
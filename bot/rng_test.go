@@ -0,0 +1,118 @@
+package bot
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// Pin a guild's seed override for one test
+func pinGuildSeed(t *testing.T, guildID string, seed int64) {
+	configMu.Lock()
+	if botConfig.Guilds == nil {
+		botConfig.Guilds = make(map[string]GuildSettings)
+	}
+	settings := botConfig.Guilds[guildID]
+	settings.RandomSeed = &seed
+	botConfig.Guilds[guildID] = settings
+	configMu.Unlock()
+	resetSeedCounter(guildID)
+
+	t.Cleanup(func() {
+		configMu.Lock()
+		delete(botConfig.Guilds, guildID)
+		configMu.Unlock()
+		resetSeedCounter(guildID)
+	})
+}
+
+// Replace seedSource with a fixed seed for one test
+func pinSeedSource(t *testing.T, seed int64) {
+	previous := seedSource
+	seedSource = func() int64 { return seed }
+	t.Cleanup(func() { seedSource = previous })
+}
+
+func TestNextSeedCountsFromOverride(t *testing.T) {
+	pinGuildSeed(t, "g1", 1000)
+
+	for want := int64(1000); want < 1005; want++ {
+		if got := nextSeed("g1"); got != want {
+			t.Fatalf("nextSeed = %d, want %d", got, want)
+		}
+	}
+
+	// Other guilds keep their own count
+	pinGuildSeed(t, "g2", 50)
+	if got := nextSeed("g2"); got != 50 {
+		t.Errorf("second guild started at %d, want 50", got)
+	}
+	if got := nextSeed("g1"); got != 1005 {
+		t.Errorf("first guild continued at %d, want 1005", got)
+	}
+}
+
+func TestResetSeedCounter(t *testing.T) {
+	pinGuildSeed(t, "g1", 7)
+	nextSeed("g1")
+	nextSeed("g1")
+
+	resetSeedCounter("g1")
+	if got := nextSeed("g1"); got != 7 {
+		t.Errorf("nextSeed after reset = %d, want 7", got)
+	}
+}
+
+func TestNextSeedWithoutOverrideUsesSource(t *testing.T) {
+	pinSeedSource(t, 42)
+	for range 3 {
+		if got := nextSeed("unpinned"); got != 42 {
+			t.Fatalf("nextSeed = %d, want the source's 42", got)
+		}
+	}
+}
+
+func TestDrawsReplayAndRecordInOrder(t *testing.T) {
+	pinSeedSource(t, 99)
+
+	draw := func() (*Draws, []int) {
+		d := newDraws("unpinned", "test")
+		values := []int{d.Intn(10), d.Intn(6)}
+		d.Shuffle(4, func(i, j int) {})
+		values = append(values, d.Intn(100))
+		return d, values
+	}
+	a, valuesA := draw()
+	b, valuesB := draw()
+
+	if a.seed != 99 || b.seed != 99 {
+		t.Fatalf("seeds %d and %d, want 99", a.seed, b.seed)
+	}
+	if !slices.Equal(valuesA, valuesB) {
+		t.Fatalf("same seed drew %v then %v", valuesA, valuesB)
+	}
+
+	want := []string{
+		strconv.Itoa(valuesA[0]) + "/10",
+		strconv.Itoa(valuesA[1]) + "/6",
+		"shuffle(4)",
+		strconv.Itoa(valuesA[2]) + "/100",
+	}
+	if !slices.Equal(a.values, want) {
+		t.Errorf("recorded %v, want %v", a.values, want)
+	}
+}
+
+func TestDrawsFollowGuildSeed(t *testing.T) {
+	pinGuildSeed(t, "g1", 500)
+	first := newDraws("g1", "test").Intn(1 << 30)
+	second := newDraws("g1", "test").Intn(1 << 30)
+
+	resetSeedCounter("g1")
+	if got := newDraws("g1", "test").Intn(1 << 30); got != first {
+		t.Errorf("first draw after reset = %d, want %d", got, first)
+	}
+	if got := newDraws("g1", "test").Intn(1 << 30); got != second {
+		t.Errorf("second draw after reset = %d, want %d", got, second)
+	}
+}
@@ -0,0 +1,586 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Defaults for zero values in RouletteSettings
+const (
+	defaultRouletteChambers = 6
+	defaultRouletteLobby    = 60 * time.Second
+	defaultRouletteTurn     = 30 * time.Second
+	defaultRouletteMute     = 30 * time.Second
+
+	roulettePenaltyMove = "move"
+	roulettePenaltyMute = "mute"
+)
+
+var (
+	rouletteStatsPath = "roulette_stats.json"
+	rouletteMutesPath = "roulette_mutes.json"
+)
+
+// RouletteStats is one member's record in a guild
+type RouletteStats struct {
+	Games      int `json:"games"`
+	Survived   int `json:"survived"`    // trigger pulls that clicked
+	Deaths     int `json:"deaths"`      // trigger pulls that didn't
+	Streak     int `json:"streak"`      // pulls survived since the last death
+	BestStreak int `json:"best_streak"` // longest streak so far
+}
+
+// A roulette game in a guild, from the lobby to the last pull
+type rouletteGame struct {
+	discord   *discordgo.Session
+	guildID   string
+	channelID string // text channel the game talks in
+	starter   string
+	players   []string
+	started   bool // past the lobby, players take turns
+	turn      int  // index into players of who pulls next
+	chambers  int
+	bullet    int // chamber holding the round
+	chamber   int // chamber under the hammer
+	timer     *time.Timer
+}
+
+var (
+	rouletteGames = make(map[string]*rouletteGame) // guildID -> game
+	rouletteStats = make(map[string]map[string]*RouletteStats)
+	rouletteMu    sync.Mutex
+
+	// Server mutes still to lift, kept on disk so a restart can't leave a
+	// loser muted for good
+	rouletteMutes   = make(map[string]map[string]time.Time) // guildID -> userID -> unmute time
+	rouletteMutesMu sync.Mutex
+)
+
+func rouletteSettings() RouletteSettings {
+	configMu.RLock()
+	settings := botConfig.Roulette
+	configMu.RUnlock()
+
+	if settings.Chambers < 2 {
+		settings.Chambers = defaultRouletteChambers
+	}
+	if settings.Penalty != roulettePenaltyMute {
+		settings.Penalty = roulettePenaltyMove
+	}
+	return settings
+}
+
+func secondsOr(seconds int, fallback time.Duration) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return fallback
+}
+
+func loadRouletteStats() error {
+	file, err := os.Open(rouletteStatsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open roulette stats: %w", err)
+	}
+	defer file.Close()
+
+	stats := make(map[string]map[string]*RouletteStats)
+	if err := json.NewDecoder(file).Decode(&stats); err != nil {
+		return fmt.Errorf("failed to decode roulette stats: %w", err)
+	}
+
+	rouletteMu.Lock()
+	rouletteStats = stats
+	rouletteMu.Unlock()
+	return nil
+}
+
+// Save the roulette stats, callers must hold rouletteMu
+func saveRouletteStats() error {
+	file, err := os.Create(rouletteStatsPath)
+	if err != nil {
+		return fmt.Errorf("failed to create roulette stats: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(rouletteStats); err != nil {
+		return fmt.Errorf("failed to encode roulette stats: %w", err)
+	}
+	return nil
+}
+
+// A member's record, created on first use. Callers must hold rouletteMu
+func playerStats(guildID, userID string) *RouletteStats {
+	if rouletteStats[guildID] == nil {
+		rouletteStats[guildID] = make(map[string]*RouletteStats)
+	}
+	if rouletteStats[guildID][userID] == nil {
+		rouletteStats[guildID][userID] = &RouletteStats{}
+	}
+	return rouletteStats[guildID][userID]
+}
+
+func (g *rouletteGame) say(content string) {
+	if _, err := g.discord.ChannelMessageSend(g.channelID, content); err != nil {
+		log.Printf("Failed to send roulette message: %v", err)
+	}
+}
+
+// Replace the pending lobby or turn timeout, callers must hold rouletteMu
+func (g *rouletteGame) schedule(after time.Duration, fire func()) {
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+	g.timer = time.AfterFunc(after, fire)
+}
+
+// Drop the game, callers must hold rouletteMu
+func (g *rouletteGame) end() {
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+	if rouletteGames[g.guildID] == g {
+		delete(rouletteGames, g.guildID)
+	}
+}
+
+// Spin the cylinder and hand the revolver to the first player, returns the
+// announcement to send once rouletteMu is released. Callers must hold it
+func (g *rouletteGame) begin() string {
+	draws := newDraws(g.guildID, "roulette")
+	draws.Shuffle(len(g.players), func(i, j int) {
+		g.players[i], g.players[j] = g.players[j], g.players[i]
+	})
+	g.started = true
+	g.bullet = draws.Intn(g.chambers)
+	draws.Log(fmt.Sprintf("order %v, round in chamber %d/%d", g.players, g.bullet+1, g.chambers))
+	g.chamber = 0
+	g.turn = 0
+
+	for _, userID := range g.players {
+		playerStats(g.guildID, userID).Games++
+	}
+
+	g.scheduleTurn()
+	return fmt.Sprintf("🔄 The cylinder spins... %d chambers, one round. <@%s>, you're up, use %spull.", g.chambers, g.players[0], guildPrefix(g.guildID))
+}
+
+// Pull the trigger for whoever is slow to, callers must hold rouletteMu
+func (g *rouletteGame) scheduleTurn() {
+	current := g.players[g.turn]
+	g.schedule(secondsOr(rouletteSettings().TurnSeconds, defaultRouletteTurn), func() {
+		rouletteMu.Lock()
+		if rouletteGames[g.guildID] != g || g.players[g.turn] != current {
+			rouletteMu.Unlock()
+			return
+		}
+		announce := g.pull()
+		rouletteMu.Unlock()
+
+		g.say(fmt.Sprintf("⌛ <@%s> hesitated, the trigger pulls itself.", current))
+		announce()
+	})
+}
+
+// Fire the current chamber for the current player. Returns what to send
+// about it, to call once rouletteMu is released. Callers must hold it
+func (g *rouletteGame) pull() func() {
+	userID := g.players[g.turn]
+	stats := playerStats(g.guildID, userID)
+
+	if g.chamber != g.bullet {
+		stats.Survived++
+		stats.Streak++
+		stats.BestStreak = max(stats.BestStreak, stats.Streak)
+		recordStat(g.guildID, userID, statRouletteSurvive, 1)
+		g.chamber++
+		g.turn = (g.turn + 1) % len(g.players)
+
+		g.scheduleTurn()
+		if err := saveRouletteStats(); err != nil {
+			log.Printf("Failed to save roulette stats: %v", err)
+		}
+		message := fmt.Sprintf("*click* 😮‍💨 <@%s> survives (chamber %d/%d). <@%s>, your turn.",
+			userID, g.chamber, g.chambers, g.players[g.turn])
+		return func() { g.say(message) }
+	}
+
+	stats.Deaths++
+	stats.Streak = 0
+	recordStat(g.guildID, userID, statRouletteDeaths, 1)
+	g.end()
+	if err := saveRouletteStats(); err != nil {
+		log.Printf("Failed to save roulette stats: %v", err)
+	}
+
+	// The penalty talks to Discord too
+	chamber := g.chamber + 1
+	return func() {
+		g.say(fmt.Sprintf("💥 BANG! <@%s> is dead (chamber %d/%d). %s", userID, chamber, g.chambers, g.punish(userID)))
+	}
+}
+
+// Move or server-mute the loser, returns what happened
+func (g *rouletteGame) punish(userID string) string {
+	discord := g.discord
+	guildID := g.guildID
+
+	vs, err := discord.State.VoiceState(guildID, userID)
+	if err != nil || vs == nil || vs.ChannelID == "" {
+		return "Lucky for them they aren't in voice."
+	}
+
+	settings := rouletteSettings()
+	if settings.Penalty == roulettePenaltyMute {
+		if err := discord.GuildMemberMute(guildID, userID, true); err != nil {
+			log.Printf("Failed to mute roulette loser %s: %v", userID, err)
+			return "The bot couldn't mute them."
+		}
+		duration := secondsOr(settings.MuteSeconds, defaultRouletteMute)
+		scheduleRouletteUnmute(discord, guildID, userID, time.Now().Add(duration))
+		return fmt.Sprintf("🔇 Muted for %s.", duration)
+	}
+
+	if reason := moveProtection(discord, guildID, userID, vs.ChannelID); reason != "" {
+		return fmt.Sprintf("🛡️ They're %s, so they stay put.", reason)
+	}
+
+	channels, err := discord.GuildChannels(guildID)
+	if err != nil {
+		log.Printf("Failed to get guild channels: %v", err)
+		return "The body couldn't be moved."
+	}
+	var destinations []string
+	for _, channel := range channels {
+		if channel.Type == discordgo.ChannelTypeGuildVoice && channel.ID != vs.ChannelID && !channelProtected(discord, guildID, channel.ID) {
+			destinations = append(destinations, channel.ID)
+		}
+	}
+	if len(destinations) == 0 {
+		return "There's nowhere to drag the body."
+	}
+
+	snapshot := takeMoveSnapshot(discord, guildID, "roulette")
+	draws := newDraws(guildID, "roulette penalty")
+	destination := destinations[draws.Intn(len(destinations))]
+	draws.Log(fmt.Sprintf("%s moved to %s", userID, destination))
+	err = snapshot.move(discord, guildID, userID, destination)
+	snapshot.save(guildID)
+	if err != nil {
+		log.Printf("Failed to move roulette loser %s: %v", userID, err)
+		return "The body couldn't be moved."
+	}
+	return fmt.Sprintf("⚰️ Dragged to <#%s>.", destination)
+}
+
+func loadRouletteMutes() error {
+	file, err := os.Open(rouletteMutesPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open roulette mutes: %w", err)
+	}
+	defer file.Close()
+
+	mutes := make(map[string]map[string]time.Time)
+	if err := json.NewDecoder(file).Decode(&mutes); err != nil {
+		return fmt.Errorf("failed to decode roulette mutes: %w", err)
+	}
+
+	rouletteMutesMu.Lock()
+	rouletteMutes = mutes
+	rouletteMutesMu.Unlock()
+	return nil
+}
+
+// Save the pending unmutes, callers must hold rouletteMutesMu
+func saveRouletteMutes() error {
+	file, err := os.Create(rouletteMutesPath)
+	if err != nil {
+		return fmt.Errorf("failed to create roulette mutes: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(rouletteMutes); err != nil {
+		return fmt.Errorf("failed to encode roulette mutes: %w", err)
+	}
+	return nil
+}
+
+// Remember a loser's unmute on disk and lift it when it's due
+func scheduleRouletteUnmute(discord *discordgo.Session, guildID, userID string, at time.Time) {
+	rouletteMutesMu.Lock()
+	if rouletteMutes[guildID] == nil {
+		rouletteMutes[guildID] = make(map[string]time.Time)
+	}
+	rouletteMutes[guildID][userID] = at
+	if err := saveRouletteMutes(); err != nil {
+		log.Printf("Failed to save roulette mutes: %v", err)
+	}
+	rouletteMutesMu.Unlock()
+
+	time.AfterFunc(time.Until(at), func() { liftRouletteMute(discord, guildID, userID) })
+}
+
+// Unmute a loser whose time is up. Discord refuses while they're out of
+// voice, then the unmute stays pending until they join again
+func liftRouletteMute(discord *discordgo.Session, guildID, userID string) {
+	rouletteMutesMu.Lock()
+	at, ok := rouletteMutes[guildID][userID]
+	rouletteMutesMu.Unlock()
+	if !ok || time.Now().Before(at) {
+		return
+	}
+
+	// A member who left the guild has nothing left to lift
+	err := discord.GuildMemberMute(guildID, userID, false)
+	var restErr *discordgo.RESTError
+	if err != nil && !(errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode == 404) {
+		log.Printf("Failed to unmute roulette loser %s, retrying when they join voice: %v", userID, err)
+		return
+	}
+
+	rouletteMutesMu.Lock()
+	defer rouletteMutesMu.Unlock()
+	// A new mute may have replaced this one meanwhile
+	if !rouletteMutes[guildID][userID].Equal(at) {
+		return
+	}
+	delete(rouletteMutes[guildID], userID)
+	if len(rouletteMutes[guildID]) == 0 {
+		delete(rouletteMutes, guildID)
+	}
+	if err := saveRouletteMutes(); err != nil {
+		log.Printf("Failed to save roulette mutes: %v", err)
+	}
+}
+
+// Pick up the unmutes left pending by the last run, call once connected
+func resumeRouletteMutes(discord *discordgo.Session) {
+	rouletteMutesMu.Lock()
+	defer rouletteMutesMu.Unlock()
+
+	for guildID, users := range rouletteMutes {
+		for userID, at := range users {
+			time.AfterFunc(time.Until(at), func() { liftRouletteMute(discord, guildID, userID) })
+		}
+	}
+}
+
+// Lift an overdue unmute when its loser joins voice
+func onRouletteVoiceJoin(discord *discordgo.Session, vsu *discordgo.VoiceStateUpdate) {
+	if vsu.ChannelID == "" {
+		return
+	}
+	rouletteMutesMu.Lock()
+	at, ok := rouletteMutes[vsu.GuildID][vsu.UserID]
+	rouletteMutesMu.Unlock()
+
+	if ok && !time.Now().Before(at) {
+		go liftRouletteMute(discord, vsu.GuildID, vsu.UserID)
+	}
+}
+
+// !roulette start|join|leave|begin|cancel|stats
+func rouletteHandler(cmd *CommandContext) {
+	switch cmd.String("action") {
+	case "start":
+		rouletteStart(cmd)
+	case "join", "leave":
+		rouletteJoin(cmd, cmd.String("action") == "join")
+	case "begin":
+		rouletteBegin(cmd)
+	case "cancel":
+		rouletteCancel(cmd)
+	case "stats":
+		rouletteStatsHandler(cmd)
+	}
+}
+
+func rouletteStart(cmd *CommandContext) {
+	rouletteMu.Lock()
+	if rouletteGames[cmd.GuildID] != nil {
+		rouletteMu.Unlock()
+		cmd.Replyf("❌ A game is already running, %sroulette join to get in.", cmd.Prefix)
+		return
+	}
+
+	settings := rouletteSettings()
+	game := &rouletteGame{
+		discord:   cmd.Discord,
+		guildID:   cmd.GuildID,
+		channelID: cmd.ChannelID,
+		starter:   cmd.Author.ID,
+		players:   []string{cmd.Author.ID},
+		chambers:  settings.Chambers,
+	}
+	rouletteGames[cmd.GuildID] = game
+
+	lobby := secondsOr(settings.LobbySeconds, defaultRouletteLobby)
+	game.schedule(lobby, func() {
+		rouletteMu.Lock()
+		if rouletteGames[game.guildID] != game || game.started {
+			rouletteMu.Unlock()
+			return
+		}
+		message := "🕸️ Nobody else joined, the roulette lobby closed."
+		if len(game.players) < 2 {
+			game.end()
+		} else {
+			message = game.begin()
+		}
+		rouletteMu.Unlock()
+		game.say(message)
+	})
+	rouletteMu.Unlock()
+
+	cmd.Replyf("🔫 <@%s> opened a roulette lobby. %sroulette join in the next %s to play, the loser gets %s.",
+		cmd.Author.ID, cmd.Prefix, lobby, map[string]string{roulettePenaltyMove: "moved", roulettePenaltyMute: "server-muted"}[settings.Penalty])
+}
+
+func rouletteJoin(cmd *CommandContext, join bool) {
+	rouletteMu.Lock()
+	reply := joinLobby(cmd, join)
+	rouletteMu.Unlock()
+
+	cmd.Reply(reply)
+}
+
+// Join or leave the lobby, returns the reply. Callers must hold rouletteMu
+func joinLobby(cmd *CommandContext, join bool) string {
+	game := rouletteGames[cmd.GuildID]
+	switch {
+	case game == nil:
+		return fmt.Sprintf("❌ No lobby is open, %sroulette start opens one.", cmd.Prefix)
+	case game.started:
+		return "❌ The game has already started."
+	}
+
+	inGame := slices.Contains(game.players, cmd.Author.ID)
+	switch {
+	case join && inGame:
+		return "❌ You're already in."
+	case join:
+		game.players = append(game.players, cmd.Author.ID)
+		return fmt.Sprintf("🔫 <@%s> joins, %d player(s) in.", cmd.Author.ID, len(game.players))
+	case !inGame:
+		return "❌ You aren't in the lobby."
+	case cmd.Author.ID == game.starter:
+		return fmt.Sprintf("❌ You started it, use %sroulette cancel instead.", cmd.Prefix)
+	}
+	game.players = slices.DeleteFunc(game.players, func(id string) bool { return id == cmd.Author.ID })
+	return fmt.Sprintf("🐔 <@%s> chickened out, %d player(s) in.", cmd.Author.ID, len(game.players))
+}
+
+func rouletteBegin(cmd *CommandContext) {
+	rouletteMu.Lock()
+	game := rouletteGames[cmd.GuildID]
+	var message string
+	switch {
+	case game == nil || game.started:
+		message = "❌ There's no lobby waiting to start."
+	case cmd.Author.ID != game.starter:
+		message = "❌ Only whoever opened the lobby can start early."
+	case len(game.players) < 2:
+		message = "❌ You need at least one more player."
+	default:
+		message = game.begin()
+	}
+	rouletteMu.Unlock()
+
+	cmd.Reply(message)
+}
+
+func rouletteCancel(cmd *CommandContext) {
+	rouletteMu.Lock()
+	game := rouletteGames[cmd.GuildID]
+	rouletteMu.Unlock()
+
+	if game == nil {
+		cmd.Reply("❌ No roulette game is running.")
+		return
+	}
+	// The level can take a REST call, so it's checked outside the lock
+	if cmd.Author.ID != game.starter && memberLevel(cmd.Discord, cmd.GuildID, cmd.ChannelID, cmd.Author.ID) < PermModerator {
+		cmd.Reply("❌ Only whoever opened the game or a moderator can cancel it.")
+		return
+	}
+
+	rouletteMu.Lock()
+	running := rouletteGames[cmd.GuildID] == game
+	if running {
+		game.end()
+	}
+	rouletteMu.Unlock()
+
+	if !running {
+		cmd.Reply("❌ That game already ended.")
+		return
+	}
+	cmd.Reply("🛑 Roulette cancelled, everyone lives.")
+}
+
+// !pull, only the player whose turn it is
+func pullHandler(cmd *CommandContext) {
+	rouletteMu.Lock()
+	game := rouletteGames[cmd.GuildID]
+	switch {
+	case game == nil || !game.started:
+		rouletteMu.Unlock()
+		cmd.Reply("❌ No roulette game is in progress.")
+	case game.players[game.turn] != cmd.Author.ID:
+		turn := game.players[game.turn]
+		rouletteMu.Unlock()
+		cmd.Replyf("❌ Wait your turn, it's <@%s>'s.", turn)
+	default:
+		announce := game.pull()
+		rouletteMu.Unlock()
+		announce()
+	}
+}
+
+func rouletteStatsHandler(cmd *CommandContext) {
+	userID := cmd.Author.ID
+	if cmd.Has("user") {
+		userID = cmd.String("user")
+	}
+
+	rouletteMu.Lock()
+	response := "🔫 **Roulette stats**\n"
+	if stats := rouletteStats[cmd.GuildID][userID]; stats != nil {
+		response += fmt.Sprintf("<@%s>: %d game(s), %d shot(s) survived, %d death(s), streak %d (best %d)\n",
+			userID, stats.Games, stats.Survived, stats.Deaths, stats.Streak, stats.BestStreak)
+	} else {
+		response += fmt.Sprintf("<@%s> hasn't played yet.\n", userID)
+	}
+
+	// Most shots survived in the guild
+	guildStats := rouletteStats[cmd.GuildID]
+	ids := make([]string, 0, len(guildStats))
+	for id := range guildStats {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return guildStats[ids[i]].Survived > guildStats[ids[j]].Survived })
+	for i, id := range ids[:min(len(ids), 5)] {
+		response += fmt.Sprintf("\n%d. <@%s> — %d survived, %d death(s)", i+1, id, guildStats[id].Survived, guildStats[id].Deaths)
+	}
+	rouletteMu.Unlock()
+
+	cmd.Reply(response)
+}
@@ -0,0 +1,251 @@
+package bot
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"strings"
+)
+
+// The slash command form of a registered command. Aliases stay text only
+func (c *Command) applicationCommand() *discordgo.ApplicationCommand {
+	command := &discordgo.ApplicationCommand{
+		Name:        c.Name,
+		Description: c.Description,
+		Options:     []*discordgo.ApplicationCommandOption{},
+	}
+
+	if c.GuildOnly {
+		command.Contexts = &[]discordgo.InteractionContextType{discordgo.InteractionContextGuild}
+	}
+
+	for _, arg := range c.Args {
+		command.Options = append(command.Options, arg.applicationOption())
+	}
+	return command
+}
+
+func (a Arg) applicationOption() *discordgo.ApplicationCommandOption {
+	description := a.Description
+	if description == "" {
+		description = capitalize(a.Name)
+	}
+
+	option := &discordgo.ApplicationCommandOption{
+		Name:        a.Name,
+		Description: description,
+		Required:    !a.Optional,
+	}
+
+	switch a.Type {
+	case ArgInt:
+		option.Type = discordgo.ApplicationCommandOptionInteger
+	case ArgUser:
+		option.Type = discordgo.ApplicationCommandOptionUser
+	case ArgChannel:
+		option.Type = discordgo.ApplicationCommandOptionChannel
+		option.ChannelTypes = []discordgo.ChannelType{discordgo.ChannelTypeGuildVoice}
+	case ArgAttachment:
+		option.Type = discordgo.ApplicationCommandOptionAttachment
+	default:
+		option.Type = discordgo.ApplicationCommandOptionString
+	}
+
+	for _, choice := range a.Choices {
+		option.Choices = append(option.Choices, &discordgo.ApplicationCommandOptionChoice{Name: choice, Value: choice})
+	}
+	return option
+}
+
+// Turn slash command options into the same argument values parseArgs makes
+func (c *Command) interactionArgs(data discordgo.ApplicationCommandInteractionData) (map[string]any, []*discordgo.MessageAttachment, error) {
+	values := make(map[string]any)
+	var attachments []*discordgo.MessageAttachment
+
+	for _, option := range data.Options {
+		var arg *Arg
+		for i := range c.Args {
+			if c.Args[i].Name == option.Name {
+				arg = &c.Args[i]
+				break
+			}
+		}
+		if arg == nil {
+			return nil, nil, fmt.Errorf("unknown option %s", option.Name)
+		}
+
+		switch arg.Type {
+		case ArgInt:
+			values[arg.Name] = int(option.IntValue())
+
+		case ArgDuration:
+			d, err := parseTimestamp(option.StringValue())
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s must be a timestamp like 1:23", arg.Name)
+			}
+			values[arg.Name] = d
+
+		case ArgUser, ArgChannel:
+			id, _ := option.Value.(string)
+			values[arg.Name] = id
+
+		case ArgAttachment:
+			id, _ := option.Value.(string)
+			if data.Resolved == nil || data.Resolved.Attachments[id] == nil {
+				return nil, nil, fmt.Errorf("%s attachment is missing", arg.Name)
+			}
+			values[arg.Name] = data.Resolved.Attachments[id]
+			attachments = append(attachments, data.Resolved.Attachments[id])
+
+		default:
+			if text := strings.TrimSpace(option.StringValue()); text != "" {
+				values[arg.Name] = text
+			}
+		}
+	}
+	return values, attachments, nil
+}
+
+// Run a slash command through the same path as its prefix form. The
+// response is deferred first since Gemini and yt-dlp can take a while
+func handleSlashCommand(discord *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	data := interaction.ApplicationCommandData()
+	command, ok := lookupCommand(data.Name)
+	if !ok {
+		respondEphemeral(discord, interaction, "❌ Unknown command.")
+		return
+	}
+
+	err := discord.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		log.Printf("Failed to defer /%s: %v", command.Name, err)
+		return
+	}
+
+	author := interaction.User
+	if interaction.Member != nil {
+		author = interaction.Member.User
+	}
+
+	cmd := &CommandContext{
+		Discord:     discord,
+		Command:     command,
+		GuildID:     interaction.GuildID,
+		ChannelID:   interaction.ChannelID,
+		Author:      author,
+		Interaction: interaction,
+		Prefix:      "/",
+		Invoked:     command.Name,
+	}
+
+	if !commandAllowedHere(cmd) {
+		return
+	}
+
+	args, attachments, err := command.interactionArgs(data)
+	if err != nil {
+		cmd.UsageError(err.Error())
+		return
+	}
+	cmd.args = args
+
+	// Helpers that still read the triggering message get an equivalent one
+	cmd.Message = &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			ChannelID:   cmd.ChannelID,
+			GuildID:     cmd.GuildID,
+			Author:      author,
+			Attachments: attachments,
+		},
+	}
+
+	executeCommand(cmd)
+	cmd.finishInteraction()
+}
+
+// The first reply fills in the deferred response, later ones are followups
+func (cmd *CommandContext) sendInteraction(msg *discordgo.MessageSend) error {
+	cmd.mu.Lock()
+	first := !cmd.responded
+	cmd.responded = true
+	cmd.mu.Unlock()
+
+	components := msg.Components
+	if components == nil {
+		components = []discordgo.MessageComponent{}
+	}
+
+	if first {
+		edit := &discordgo.WebhookEdit{
+			Content:    &msg.Content,
+			Components: &components,
+			Files:      msg.Files,
+		}
+		if len(msg.Embeds) > 0 {
+			edit.Embeds = &msg.Embeds
+		}
+		_, err := cmd.Discord.InteractionResponseEdit(cmd.Interaction.Interaction, edit)
+		return err
+	}
+
+	_, err := cmd.Discord.FollowupMessageCreate(cmd.Interaction.Interaction, true, &discordgo.WebhookParams{
+		Content:    msg.Content,
+		Embeds:     msg.Embeds,
+		Components: components,
+		Files:      msg.Files,
+	})
+	return err
+}
+
+// Drop the "thinking..." placeholder when the command only posted to the
+// channel itself, like the queue and now-playing messages do
+func (cmd *CommandContext) finishInteraction() {
+	cmd.mu.Lock()
+	responded := cmd.responded
+	cmd.mu.Unlock()
+
+	if responded {
+		return
+	}
+	if err := cmd.Discord.InteractionResponseDelete(cmd.Interaction.Interaction); err != nil {
+		log.Printf("Failed to clear deferred /%s response: %v", cmd.Command.Name, err)
+	}
+}
+
+// Register every command as a slash command, per guild when guild IDs are
+// configured (instant) or globally otherwise (can take up to an hour)
+func syncSlashCommands(discord *discordgo.Session) {
+	configMu.RLock()
+	settings := botConfig.SlashCommands
+	configMu.RUnlock()
+
+	if settings.Disabled {
+		log.Print("Slash commands are disabled in the config")
+		return
+	}
+
+	var appCommands []*discordgo.ApplicationCommand
+	for _, command := range commandList {
+		appCommands = append(appCommands, command.applicationCommand())
+	}
+
+	appID := discord.State.User.ID
+	guildIDs := settings.GuildIDs
+	if len(guildIDs) == 0 {
+		guildIDs = []string{""}
+	}
+
+	for _, guildID := range guildIDs {
+		if _, err := discord.ApplicationCommandBulkOverwrite(appID, guildID, appCommands); err != nil {
+			log.Printf("Failed to sync slash commands (guild %q): %v", guildID, err)
+			continue
+		}
+		if guildID == "" {
+			log.Printf("Synced %d global slash commands", len(appCommands))
+		} else {
+			log.Printf("Synced %d slash commands to guild %s", len(appCommands), guildID)
+		}
+	}
+}
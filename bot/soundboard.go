@@ -0,0 +1,426 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSoundDir       = "sounds"
+	defaultMaxSoundLength = 10
+	defaultMaxSoundUpload = 8 // MB
+	soundIndexFile        = "index.json"
+)
+
+var soundNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// Sound is one soundboard entry. File is relative to the sound directory
+type Sound struct {
+	Name           string   `json:"name"`
+	File           string   `json:"file"`
+	Aliases        []string `json:"aliases,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+	VolumeOffsetDB float64  `json:"volume_offset_db"`
+	GuildID        string   `json:"guild_id,omitempty"` // empty for sounds every guild can use
+	AddedBy        string   `json:"added_by,omitempty"`
+}
+
+// SoundIndex is the structure of the soundboard's index.json
+type SoundIndex struct {
+	Sounds []Sound `json:"sounds"`
+}
+
+var (
+	soundIndex   SoundIndex
+	soundIndexMu sync.RWMutex
+)
+
+func soundboardSettings() SoundboardSettings {
+	configMu.RLock()
+	settings := botConfig.Soundboard
+	configMu.RUnlock()
+
+	if settings.Dir == "" {
+		settings.Dir = defaultSoundDir
+	}
+	if settings.MaxLengthSeconds <= 0 {
+		settings.MaxLengthSeconds = defaultMaxSoundLength
+	}
+	if settings.MaxUploadMB <= 0 {
+		settings.MaxUploadMB = defaultMaxSoundUpload
+	}
+	return settings
+}
+
+// Load the soundboard index - call this when bot starts
+func loadSoundboard() error {
+	soundIndexMu.Lock()
+	defer soundIndexMu.Unlock()
+
+	path := filepath.Join(soundboardSettings().Dir, soundIndexFile)
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open soundboard index: %w", err)
+	}
+	defer file.Close()
+
+	var index SoundIndex
+	if err := json.NewDecoder(file).Decode(&index); err != nil {
+		return fmt.Errorf("failed to decode soundboard index: %w", err)
+	}
+	soundIndex = index
+
+	log.Printf("Loaded %d soundboard sound(s)", len(soundIndex.Sounds))
+	return nil
+}
+
+// Save the soundboard index, callers must hold soundIndexMu
+func saveSoundboard() error {
+	path := filepath.Join(soundboardSettings().Dir, soundIndexFile)
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create soundboard index: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(&soundIndex); err != nil {
+		return fmt.Errorf("failed to encode soundboard index: %w", err)
+	}
+	return nil
+}
+
+func (s *Sound) matches(name string) bool {
+	if s.Name == name {
+		return true
+	}
+	for _, alias := range s.Aliases {
+		if strings.EqualFold(alias, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Sound) hasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Find a sound by name or alias, the guild's own sounds win over shared ones
+func findSound(guildID, name string) (Sound, bool) {
+	soundIndexMu.RLock()
+	defer soundIndexMu.RUnlock()
+
+	name = strings.ToLower(name)
+	var shared *Sound
+	for i := range soundIndex.Sounds {
+		sound := &soundIndex.Sounds[i]
+		if !sound.matches(name) {
+			continue
+		}
+		if sound.GuildID == guildID && guildID != "" {
+			return *sound, true
+		}
+		if sound.GuildID == "" && shared == nil {
+			shared = sound
+		}
+	}
+
+	if shared != nil {
+		return *shared, true
+	}
+	return Sound{}, false
+}
+
+// Sounds usable in a guild, optionally filtered by tag, sorted by name
+func listSounds(guildID, tag string) []Sound {
+	soundIndexMu.RLock()
+	defer soundIndexMu.RUnlock()
+
+	var sounds []Sound
+	for _, sound := range soundIndex.Sounds {
+		if sound.GuildID != "" && sound.GuildID != guildID {
+			continue
+		}
+		if tag != "" && !sound.hasTag(tag) {
+			continue
+		}
+		sounds = append(sounds, sound)
+	}
+
+	sort.Slice(sounds, func(i, j int) bool { return sounds[i].Name < sounds[j].Name })
+	return sounds
+}
+
+func (s *Sound) path() string {
+	return filepath.Join(soundboardSettings().Dir, s.File)
+}
+
+func (s *Sound) track() *Track {
+	track := newTrack(s.path(), "", "")
+	track.Title = s.Name
+	track.VolumeOffsetDB = s.VolumeOffsetDB
+	return track
+}
+
+// Connect to the caller's channel and queue a soundboard sound
+func playSound(cmd *CommandContext, name string) {
+	sound, ok := findSound(cmd.GuildID, name)
+	if !ok {
+		cmd.Replyf("❌ Unknown sound **%s**. Try %ssb list", name, cmd.Prefix)
+		return
+	}
+
+	if !botConnect(cmd) {
+		return
+	}
+
+	session, ok := getVoiceSession(cmd.GuildID)
+	if !ok {
+		cmd.Reply("❌ Bot is not connected to a voice channel.")
+		return
+	}
+
+	track := sound.track()
+	track.ChannelID = cmd.ChannelID
+	track.Requester = cmd.Author.ID
+	enqueueTrack(cmd.Discord, session, track)
+}
+
+// Download an attached clip, trim it and register it for the guild. Adding
+// needs DJ, playing and listing stay open to everyone
+func addSound(cmd *CommandContext, name string, tags []string) {
+	guildID := cmd.GuildID
+	attachment := cmd.Attachment("file")
+	settings := soundboardSettings()
+	maxUpload := int64(settings.MaxUploadMB) << 20
+
+	if memberLevel(cmd.Discord, guildID, cmd.ChannelID, cmd.Author.ID) < PermDJ {
+		cmd.Replyf("🔒 %ssb add needs the **%s** permission level.", cmd.Prefix, PermDJ)
+		return
+	}
+
+	if !soundNamePattern.MatchString(name) || name == "list" || name == "add" {
+		cmd.Reply("❌ Sound names use 1-32 lowercase letters, digits, - or _ (and can't be list or add).")
+		return
+	}
+	if attachment == nil {
+		cmd.Replyf("❌ Attach an audio file to %ssb add <name>.", cmd.Prefix)
+		return
+	}
+	if int64(attachment.Size) > maxUpload {
+		cmd.Replyf("❌ Uploads can be at most %d MB.", settings.MaxUploadMB)
+		return
+	}
+	if _, exists := findSound(guildID, name); exists {
+		cmd.Replyf("❌ A sound called **%s** already exists.", name)
+		return
+	}
+
+	upload, err := downloadAttachment(attachment.URL, fmt.Sprintf("sb_upload_%d_%s", time.Now().UnixNano(), filepath.Base(attachment.Filename)), maxUpload)
+	if errors.Is(err, errDownloadTooLarge) {
+		os.Remove(upload)
+		cmd.Replyf("❌ Uploads can be at most %d MB.", settings.MaxUploadMB)
+		return
+	}
+	if err != nil {
+		cmd.Reply("❌ Failed to download the attachment.")
+		return
+	}
+	defer os.Remove(upload)
+
+	duration, err := verifyAudioFile(upload)
+	if err != nil {
+		cmd.Reply("❌ That doesn't look like an audio file: " + err.Error())
+		return
+	}
+
+	relPath := filepath.Join(guildID, name+".mp3")
+	target := filepath.Join(settings.Dir, relPath)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		cmd.Reply("❌ Failed to create the sound directory.")
+		return
+	}
+
+	// Re-encode to mp3, cutting anything past the maximum length
+	maxLength := time.Duration(settings.MaxLengthSeconds) * time.Second
+	ffmpeg := exec.Command("ffmpeg", "-y", "-i", upload, "-t", fmt.Sprintf("%d", settings.MaxLengthSeconds), "-vn", "-acodec", "libmp3lame", target)
+	if output, err := ffmpeg.CombinedOutput(); err != nil {
+		log.Printf("Failed to convert soundboard upload: %v\nOutput: %s", err, string(output))
+		cmd.Reply("❌ Failed to convert the audio file.")
+		return
+	}
+
+	soundIndexMu.Lock()
+	soundIndex.Sounds = append(soundIndex.Sounds, Sound{
+		Name:    name,
+		File:    relPath,
+		Tags:    tags,
+		GuildID: guildID,
+		AddedBy: cmd.Author.ID,
+	})
+	err = saveSoundboard()
+	soundIndexMu.Unlock()
+
+	if err != nil {
+		cmd.Reply("❌ Failed to save the soundboard: " + err.Error())
+		return
+	}
+
+	response := fmt.Sprintf("✅ Added sound **%s**.", name)
+	if duration > maxLength {
+		response += fmt.Sprintf(" It was trimmed to %ds.", settings.MaxLengthSeconds)
+	}
+	cmd.Reply(response)
+}
+
+func soundboardHandler(cmd *CommandContext) {
+	args := strings.Fields(cmd.String("args"))
+	if len(args) == 0 {
+		cmd.UsageError("")
+		return
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "list":
+		var tag string
+		if len(args) > 1 {
+			tag = args[1]
+		}
+
+		sounds := listSounds(cmd.GuildID, tag)
+		if len(sounds) == 0 {
+			cmd.Reply("📭 No sounds found.")
+			return
+		}
+
+		response := fmt.Sprintf("🔈 **Soundboard (%d):**\n", len(sounds))
+		for _, sound := range sounds {
+			response += "• `" + sound.Name + "`"
+			if len(sound.Aliases) > 0 {
+				response += " (" + strings.Join(sound.Aliases, ", ") + ")"
+			}
+			if len(sound.Tags) > 0 {
+				response += " [" + strings.Join(sound.Tags, ", ") + "]"
+			}
+			response += "\n"
+		}
+		response = truncateLines(response, 2000)
+		cmd.Reply(response)
+
+	case "add":
+		if len(args) < 2 {
+			cmd.UsageError("add needs a name and an audio attachment")
+			return
+		}
+		addSound(cmd, strings.ToLower(args[1]), args[2:])
+
+	default:
+		playSound(cmd, args[0])
+	}
+}
+
+var (
+	errUnknownSound = errors.New("unknown sound")
+
+	// Anything that looks like "scheme:" (http:, file:, concat:, C:, ...)
+	protocolPrefix = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+
+	// File types !play reads straight from the sound directories
+	audioExtensions = []string{".mp3", ".ogg", ".opus", ".wav", ".flac", ".m4a", ".webm", ".aac"}
+)
+
+// Directories !play is allowed to read from, the soundboard's first
+func soundDirs() []string {
+	settings := soundboardSettings()
+	return append([]string{settings.Dir}, settings.ExtraDirs...)
+}
+
+func isAudioFile(path string) bool {
+	return slices.Contains(audioExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
+// Turn user input into a playable track. Soundboard names and aliases come
+// first, then audio files at the top of the sound directories or in the
+// caller's own guild folder. Absolute paths, traversal, protocol prefixes
+// and other guilds' uploads are rejected before touching the disk
+func resolveSound(guildID, input string) (*Track, error) {
+	if input == "" {
+		return nil, errUnknownSound
+	}
+
+	if sound, ok := findSound(guildID, input); ok {
+		return sound.track(), nil
+	}
+
+	switch {
+	case strings.ContainsRune(input, 0):
+		return nil, fmt.Errorf("sound name contains a NUL byte")
+	case protocolPrefix.MatchString(input):
+		return nil, fmt.Errorf("protocol prefixes are not allowed")
+	case filepath.IsAbs(input) || strings.HasPrefix(input, "/") || strings.HasPrefix(input, `\`):
+		return nil, fmt.Errorf("absolute paths are not allowed")
+	}
+	parts := strings.FieldsFunc(input, func(r rune) bool { return r == '/' || r == '\\' })
+	for _, part := range parts {
+		if part == ".." {
+			return nil, fmt.Errorf("path traversal is not allowed")
+		}
+	}
+	parts = slices.DeleteFunc(parts, func(part string) bool { return part == "." })
+	if len(parts) > 2 || (len(parts) == 2 && parts[0] != guildID) {
+		return nil, errUnknownSound
+	}
+	if !isAudioFile(input) {
+		return nil, errUnknownSound
+	}
+
+	for _, dir := range soundDirs() {
+		if path, ok := fileInDir(dir, input); ok && isAudioFile(path) {
+			return newTrack(path, "", ""), nil
+		}
+	}
+	return nil, errUnknownSound
+}
+
+// Join name onto dir and check the result, symlinks resolved, is a regular
+// file that still lives inside dir
+func fileInDir(dir, name string) (string, bool) {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", false
+	}
+
+	path, err := filepath.EvalSymlinks(filepath.Join(dir, name))
+	if err != nil {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	return path, true
+}
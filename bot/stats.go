@@ -0,0 +1,391 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Counters the bot keeps per user
+const (
+	statGambleSpins     = "gamble_spins"
+	statGambleWins      = "gamble_wins"
+	statShotsFired      = "shots_fired" // !shoot used on someone
+	statTimesShot       = "times_shot"  // moved by !shoot
+	statRouletteDeaths  = "roulette_deaths"
+	statRouletteSurvive = "roulette_survived" // trigger pulls that clicked
+	statCommands        = "commands"
+	statVoiceTime       = "voice_seconds"
+
+	viewAllTime = "alltime"
+	viewWeekly  = "weekly"
+
+	leaderboardPageSize = 10
+	leaderboardColor    = 0xFEE75C
+	leaderboardPrefix   = "lb:"
+
+	statsSaveInterval = time.Minute
+)
+
+var statsPath = "stats.json"
+
+// Counter is one user's tally of one stat
+type Counter struct {
+	AllTime int64 `json:"all_time"`
+	Weekly  int64 `json:"weekly"`
+}
+
+// GuildStats holds every counter in a guild
+type GuildStats struct {
+	WeekStart time.Time                      `json:"week_start"` // when the weekly counters were last reset
+	Counters  map[string]map[string]*Counter `json:"counters"`   // stat -> userID -> counter
+}
+
+// A !leaderboard board: the stat it ranks by and how each entry reads
+type leaderboard struct {
+	title  string
+	stat   string
+	format func(stats *GuildStats, userID, view string) string
+}
+
+var leaderboards = map[string]leaderboard{
+	"gamble": {"🎰 Gamble", statGambleWins, func(stats *GuildStats, userID, view string) string {
+		return fmt.Sprintf("%d win(s) in %d spin(s)", stats.value(statGambleWins, userID, view), stats.value(statGambleSpins, userID, view))
+	}},
+	"roulette": {"🔫 Roulette", statRouletteDeaths, func(stats *GuildStats, userID, view string) string {
+		return fmt.Sprintf("died %d time(s), survived %d pull(s)", stats.value(statRouletteDeaths, userID, view), stats.value(statRouletteSurvive, userID, view))
+	}},
+	"shoot": {"🎯 Shoot", statTimesShot, func(stats *GuildStats, userID, view string) string {
+		return fmt.Sprintf("shot %d time(s), fired %d", stats.value(statTimesShot, userID, view), stats.value(statShotsFired, userID, view))
+	}},
+	"commands": {"⌨️ Commands", statCommands, func(stats *GuildStats, userID, view string) string {
+		return fmt.Sprintf("%d command(s)", stats.value(statCommands, userID, view))
+	}},
+	"voice": {"🎙️ Voice time", statVoiceTime, func(stats *GuildStats, userID, view string) string {
+		return (time.Duration(stats.value(statVoiceTime, userID, view)) * time.Second).String()
+	}},
+}
+
+// Order boards are offered in
+var leaderboardNames = []string{"gamble", "roulette", "shoot", "commands", "voice"}
+
+var (
+	guildStats = make(map[string]*GuildStats) // guildID -> stats
+	statsDirty bool                           // changed since the last save
+	statsMu    sync.Mutex
+
+	// When each member joined voice, keyed "guildID:userID"
+	voiceJoins   = make(map[string]time.Time)
+	voiceJoinsMu sync.Mutex
+)
+
+func (s *GuildStats) value(stat, userID, view string) int64 {
+	counter := s.Counters[stat][userID]
+	switch {
+	case counter == nil:
+		return 0
+	case view == viewWeekly:
+		return counter.Weekly
+	default:
+		return counter.AllTime
+	}
+}
+
+// The most recent weekly reset at or before now, from the configured
+// weekday, hour and timezone
+func lastWeeklyReset(now time.Time) time.Time {
+	configMu.RLock()
+	settings := botConfig.Leaderboards
+	configMu.RUnlock()
+
+	location := time.UTC
+	if settings.Timezone != "" {
+		if loc, err := time.LoadLocation(settings.Timezone); err == nil {
+			location = loc
+		} else {
+			log.Printf("Unknown leaderboard timezone %q: %v", settings.Timezone, err)
+		}
+	}
+
+	weekday := time.Monday
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), settings.ResetDay) {
+			weekday = day
+		}
+	}
+
+	now = now.In(location)
+	reset := time.Date(now.Year(), now.Month(), now.Day(), settings.ResetHour, 0, 0, 0, location)
+	reset = reset.AddDate(0, 0, -int((now.Weekday()-weekday+7)%7))
+	if reset.After(now) {
+		reset = reset.AddDate(0, 0, -7)
+	}
+	return reset
+}
+
+// A guild's stats with the weekly counters reset when a reset has passed,
+// created on first use. Callers must hold statsMu
+func statsFor(guildID string) *GuildStats {
+	stats := guildStats[guildID]
+	if stats == nil {
+		stats = &GuildStats{WeekStart: lastWeeklyReset(time.Now())}
+		guildStats[guildID] = stats
+	}
+	if stats.Counters == nil {
+		stats.Counters = make(map[string]map[string]*Counter)
+	}
+
+	if reset := lastWeeklyReset(time.Now()); stats.WeekStart.Before(reset) {
+		for _, users := range stats.Counters {
+			for _, counter := range users {
+				counter.Weekly = 0
+			}
+		}
+		stats.WeekStart = reset
+		statsDirty = true
+	}
+	return stats
+}
+
+// Add to a user's counter, saved with the next periodic save
+func recordStat(guildID, userID, stat string, amount int64) {
+	if guildID == "" || amount == 0 {
+		return
+	}
+
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	stats := statsFor(guildID)
+	if stats.Counters[stat] == nil {
+		stats.Counters[stat] = make(map[string]*Counter)
+	}
+	counter := stats.Counters[stat][userID]
+	if counter == nil {
+		counter = &Counter{}
+		stats.Counters[stat][userID] = counter
+	}
+	counter.AllTime += amount
+	counter.Weekly += amount
+	statsDirty = true
+}
+
+// Count voice time from joins and leaves. Moving between channels keeps the
+// session going, the AFK channel doesn't count
+func recordVoiceSession(discord *discordgo.Session, vsu *discordgo.VoiceStateUpdate) {
+	key := vsu.GuildID + ":" + vsu.UserID
+	inVoice := vsu.ChannelID != ""
+	if guild, err := discord.State.Guild(vsu.GuildID); err == nil && guild.AfkChannelID == vsu.ChannelID {
+		inVoice = false
+	}
+
+	voiceJoinsMu.Lock()
+	defer voiceJoinsMu.Unlock()
+
+	joined, tracking := voiceJoins[key]
+	switch {
+	case inVoice && !tracking:
+		voiceJoins[key] = time.Now()
+	case !inVoice && tracking:
+		delete(voiceJoins, key)
+		recordStat(vsu.GuildID, vsu.UserID, statVoiceTime, int64(time.Since(joined).Seconds()))
+	}
+}
+
+// Credit everyone still in voice up to now, so saved stats include sessions
+// in progress
+func flushVoiceSessions() {
+	voiceJoinsMu.Lock()
+	defer voiceJoinsMu.Unlock()
+
+	now := time.Now()
+	for key, joined := range voiceJoins {
+		guildID, userID, _ := strings.Cut(key, ":")
+		recordStat(guildID, userID, statVoiceTime, int64(now.Sub(joined).Seconds()))
+		voiceJoins[key] = joined.Add(now.Sub(joined).Truncate(time.Second))
+	}
+}
+
+func loadStats() error {
+	file, err := os.Open(statsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open stats: %w", err)
+	}
+	defer file.Close()
+
+	stats := make(map[string]*GuildStats)
+	if err := json.NewDecoder(file).Decode(&stats); err != nil {
+		return fmt.Errorf("failed to decode stats: %w", err)
+	}
+
+	statsMu.Lock()
+	guildStats = stats
+	statsMu.Unlock()
+	return nil
+}
+
+// Write the stats when anything changed since the last save
+func saveStats() error {
+	flushVoiceSessions()
+
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	if !statsDirty {
+		return nil
+	}
+
+	file, err := os.Create(statsPath)
+	if err != nil {
+		return fmt.Errorf("failed to create stats: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(guildStats); err != nil {
+		return fmt.Errorf("failed to encode stats: %w", err)
+	}
+	statsDirty = false
+	return nil
+}
+
+// Counters change on nearly every command, so they are saved on a timer
+func runStatsSaver(stop <-chan struct{}) {
+	ticker := time.NewTicker(statsSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := saveStats(); err != nil {
+				log.Printf("Failed to save stats: %v", err)
+			}
+		}
+	}
+}
+
+// Ranked lines for a board, best first
+func leaderboardLines(guildID, board, view string) []string {
+	lb := leaderboards[board]
+
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	stats := statsFor(guildID)
+	var userIDs []string
+	for userID := range stats.Counters[lb.stat] {
+		if stats.value(lb.stat, userID, view) > 0 {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	sort.Slice(userIDs, func(i, j int) bool {
+		return stats.value(lb.stat, userIDs[i], view) > stats.value(lb.stat, userIDs[j], view)
+	})
+
+	lines := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		lines[i] = fmt.Sprintf("**%d.** <@%s> — %s", i+1, userID, lb.format(stats, userID, view))
+	}
+	return lines
+}
+
+func leaderboardEmbed(guildID, board, view string, page int) (*discordgo.MessageEmbed, []discordgo.MessageComponent) {
+	lines := leaderboardLines(guildID, board, view)
+	pages := max(1, (len(lines)+leaderboardPageSize-1)/leaderboardPageSize)
+	page = min(max(page, 0), pages-1)
+
+	viewName := "All time"
+	if view == viewWeekly {
+		viewName = "This week"
+	}
+
+	description := "Nobody is on the board yet."
+	if len(lines) > 0 {
+		description = strings.Join(lines[page*leaderboardPageSize:min(len(lines), (page+1)*leaderboardPageSize)], "\n")
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("%s — %s", leaderboards[board].title, viewName),
+		Description: description,
+		Color:       leaderboardColor,
+		Footer:      &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Page %d/%d", page+1, pages)},
+	}
+	if view == viewWeekly {
+		statsMu.Lock()
+		embed.Footer.Text += " • Week started " + statsFor(guildID).WeekStart.Format("Mon Jan 2 15:04 MST")
+		statsMu.Unlock()
+	}
+
+	if pages < 2 {
+		return embed, []discordgo.MessageComponent{}
+	}
+	// lb:<board>:<view>:<page>
+	customID := func(page int) string {
+		return leaderboardPrefix + board + ":" + view + ":" + strconv.Itoa(page)
+	}
+	return embed, []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{Emoji: &discordgo.ComponentEmoji{Name: "◀️"}, Style: discordgo.SecondaryButton,
+					CustomID: customID(page - 1), Disabled: page == 0},
+				discordgo.Button{Emoji: &discordgo.ComponentEmoji{Name: "▶️"}, Style: discordgo.SecondaryButton,
+					CustomID: customID(page + 1), Disabled: page == pages-1},
+			},
+		},
+	}
+}
+
+// !leaderboard [gamble|roulette|shoot|commands|voice] [weekly|alltime]
+func leaderboardHandler(cmd *CommandContext) {
+	board := cmd.String("board")
+	if board == "" {
+		board = leaderboardNames[0]
+	}
+	view := cmd.String("view")
+	if view == "" {
+		view = viewAllTime
+	}
+
+	embed, components := leaderboardEmbed(cmd.GuildID, board, view, 0)
+	cmd.ReplyEmbed(embed, components)
+}
+
+// Flip a leaderboard message to the page in the button's custom ID
+func handleLeaderboardButton(discord *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	parts := strings.Split(strings.TrimPrefix(interaction.MessageComponentData().CustomID, leaderboardPrefix), ":")
+	if len(parts) != 3 {
+		respondEphemeral(discord, interaction, "❌ That leaderboard no longer exists.")
+		return
+	}
+	page, err := strconv.Atoi(parts[2])
+	if _, ok := leaderboards[parts[0]]; !ok || err != nil {
+		respondEphemeral(discord, interaction, "❌ That leaderboard no longer exists.")
+		return
+	}
+
+	embed, components := leaderboardEmbed(interaction.GuildID, parts[0], parts[1], page)
+	err = discord.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: components,
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to update leaderboard message: %v", err)
+	}
+}
@@ -0,0 +1,271 @@
+package bot
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const teamsColor = 0x57F287
+
+// The last !teams split in a guild, kept for reroll and return
+type teamSplit struct {
+	homeChannelID string     // voice channel the players came from
+	players       []string   // user IDs in the split
+	channels      []string   // one voice channel per team, empty when nobody is moved
+	teams         [][]string // user IDs per team
+}
+
+var (
+	teamSplits   = make(map[string]*teamSplit) // guildID -> last split
+	teamSplitsMu sync.Mutex
+)
+
+// Deal the players into n teams of sizes that differ by at most one
+func splitTeams(guildID string, players []string, n int) [][]string {
+	draws := newDraws(guildID, "teams")
+	shuffled := slices.Clone(players)
+	draws.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	teams := make([][]string, n)
+	for i, userID := range shuffled {
+		teams[i%n] = append(teams[i%n], userID)
+	}
+	draws.Log(fmt.Sprintf("teams %v", teams))
+	return teams
+}
+
+func teamsEmbed(split *teamSplit) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("🎲 %d Teams", len(split.teams)),
+		Color: teamsColor,
+	}
+	for i, team := range split.teams {
+		mentions := make([]string, len(team))
+		for j, userID := range team {
+			mentions[j] = "<@" + userID + ">"
+		}
+		value := strings.Join(mentions, "\n")
+		if len(split.channels) > 0 {
+			value += "\n→ <#" + split.channels[i] + ">"
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: fmt.Sprintf("Team %d", i+1), Value: value, Inline: true})
+	}
+	return embed
+}
+
+// Everyone in a voice channel except bots
+func teamPlayers(cmd *CommandContext, channelID string) ([]string, error) {
+	discord := cmd.Discord
+	states, err := gatherUsersVoiceStates(discord, cmd.Message, cmd.GuildID, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	var players []string
+	for _, vs := range states {
+		if member, err := discord.State.Member(cmd.GuildID, vs.UserID); err == nil && member.User != nil && member.User.Bot {
+			continue
+		}
+		if discord.State.User != nil && vs.UserID == discord.State.User.ID {
+			continue
+		}
+		players = append(players, vs.UserID)
+	}
+	return players, nil
+}
+
+// Move every team into its channel, protected members stay put
+func moveTeams(cmd *CommandContext, split *teamSplit) []skippedMove {
+	discord := cmd.Discord
+	guildID := cmd.GuildID
+	snapshot := takeMoveSnapshot(discord, guildID, cmd.Command.Name)
+	defer snapshot.save(guildID)
+
+	var skipped []skippedMove
+	for i, team := range split.teams {
+		for _, userID := range team {
+			vs, err := discord.State.VoiceState(guildID, userID)
+			if err != nil || vs == nil || vs.ChannelID == "" || vs.ChannelID == split.channels[i] {
+				continue
+			}
+			if reason := moveProtection(discord, guildID, userID, vs.ChannelID); reason != "" {
+				skipped = append(skipped, skippedMove{userID: userID, reason: reason})
+				continue
+			}
+			if err := snapshot.move(discord, guildID, userID, split.channels[i]); err != nil {
+				log.Printf("Failed to move user %s to team channel: %v", userID, err)
+			}
+		}
+	}
+	return skipped
+}
+
+// !teams <n> [#channels...], !teams reroll and !teams return
+func teamsHandler(cmd *CommandContext) {
+	switch action := strings.ToLower(cmd.String("action")); action {
+	case "reroll":
+		teamsReroll(cmd)
+	case "return":
+		teamsReturn(cmd)
+	default:
+		n, err := strconv.Atoi(action)
+		if err != nil {
+			cmd.UsageError("give a number of teams, reroll or return")
+			return
+		}
+		teamsSplit(cmd, n)
+	}
+}
+
+func teamsSplit(cmd *CommandContext, n int) {
+	discord := cmd.Discord
+	guildID := cmd.GuildID
+
+	voiceState, err := discord.State.VoiceState(guildID, cmd.Author.ID)
+	if err != nil || voiceState == nil || voiceState.ChannelID == "" {
+		cmd.Reply("❌ You must be in a voice channel to use this command.")
+		return
+	}
+
+	players, err := teamPlayers(cmd, voiceState.ChannelID)
+	if err != nil {
+		cmd.Reply("❌ Failed to get users voice states.")
+		return
+	}
+	if n < 2 || n > len(players) {
+		cmd.Replyf("❌ Pick between 2 and %d teams for the %d people in your channel.", max(2, len(players)), len(players))
+		return
+	}
+
+	var channelIDs []string
+	for _, word := range strings.Fields(cmd.String("channels")) {
+		id, ok := mentionID(word, "#")
+		if !ok {
+			cmd.Replyf("❌ %s isn't a channel.", word)
+			return
+		}
+		channel, err := discord.State.Channel(id)
+		if err != nil || channel.GuildID != guildID || channel.Type != discordgo.ChannelTypeGuildVoice {
+			cmd.Replyf("❌ %s isn't a voice channel in this server.", word)
+			return
+		}
+		if channelProtected(discord, guildID, id) {
+			cmd.Replyf("🛡️ %s is protected, nobody can be moved there.", word)
+			return
+		}
+		channelIDs = append(channelIDs, id)
+	}
+	if len(channelIDs) > 0 && len(channelIDs) != n {
+		cmd.Replyf("❌ Give one voice channel per team, %d for %d teams.", n, n)
+		return
+	}
+
+	split := &teamSplit{
+		homeChannelID: voiceState.ChannelID,
+		players:       players,
+		channels:      channelIDs,
+		teams:         splitTeams(guildID, players, n),
+	}
+
+	teamSplitsMu.Lock()
+	teamSplits[guildID] = split
+	teamSplitsMu.Unlock()
+
+	postTeams(cmd, split)
+}
+
+// Shuffle the same players into new teams of the same count
+func teamsReroll(cmd *CommandContext) {
+	teamSplitsMu.Lock()
+	previous := teamSplits[cmd.GuildID]
+	teamSplitsMu.Unlock()
+
+	if previous == nil {
+		cmd.Replyf("❌ No teams to reroll, start with %steams <n>.", cmd.Prefix)
+		return
+	}
+
+	// Only players still in voice get a place
+	var players []string
+	for _, userID := range previous.players {
+		if vs, err := cmd.Discord.State.VoiceState(cmd.GuildID, userID); err == nil && vs != nil && vs.ChannelID != "" {
+			players = append(players, userID)
+		}
+	}
+	if len(players) < len(previous.teams) {
+		cmd.Replyf("❌ Only %d player(s) are still in voice, not enough for %d teams.", len(players), len(previous.teams))
+		return
+	}
+
+	split := &teamSplit{
+		homeChannelID: previous.homeChannelID,
+		players:       players,
+		channels:      previous.channels,
+		teams:         splitTeams(cmd.GuildID, players, len(previous.teams)),
+	}
+
+	teamSplitsMu.Lock()
+	teamSplits[cmd.GuildID] = split
+	teamSplitsMu.Unlock()
+
+	postTeams(cmd, split)
+}
+
+func postTeams(cmd *CommandContext, split *teamSplit) {
+	var skipped []skippedMove
+	if len(split.channels) > 0 {
+		skipped = moveTeams(cmd, split)
+	}
+
+	embed := teamsEmbed(split)
+	if summary := skippedSummary(skipped); summary != "" {
+		embed.Description = strings.TrimPrefix(summary, "\n")
+	}
+	cmd.ReplyEmbed(embed, nil)
+}
+
+// Bring every player still in voice back to the channel the teams came from
+func teamsReturn(cmd *CommandContext) {
+	discord := cmd.Discord
+	guildID := cmd.GuildID
+
+	teamSplitsMu.Lock()
+	split := teamSplits[guildID]
+	delete(teamSplits, guildID)
+	teamSplitsMu.Unlock()
+
+	if split == nil {
+		cmd.Reply("❌ There are no teams to bring back.")
+		return
+	}
+
+	snapshot := takeMoveSnapshot(discord, guildID, cmd.Command.Name)
+	defer snapshot.save(guildID)
+
+	var movedCount int
+	var skipped []skippedMove
+	for _, userID := range split.players {
+		vs, err := discord.State.VoiceState(guildID, userID)
+		if err != nil || vs == nil || vs.ChannelID == "" || vs.ChannelID == split.homeChannelID {
+			continue
+		}
+		if reason := moveProtection(discord, guildID, userID, vs.ChannelID); reason != "" {
+			skipped = append(skipped, skippedMove{userID: userID, reason: reason})
+			continue
+		}
+		if err := snapshot.move(discord, guildID, userID, split.homeChannelID); err != nil {
+			log.Printf("Failed to move user %s back from teams: %v", userID, err)
+			continue
+		}
+		movedCount++
+	}
+
+	cmd.Replyf("🏠 Brought %d player(s) back to <#%s>.%s", movedCount, split.homeChannelID, skippedSummary(skipped))
+}
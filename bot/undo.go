@@ -0,0 +1,140 @@
+package bot
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"sync"
+	"time"
+)
+
+// How long !undo works after a move when the config doesn't say
+const defaultUndoWindow = 5 * time.Minute
+
+// Where everyone was before a voice-moving command ran, and where it put them
+type moveSnapshot struct {
+	command string
+	taken   time.Time
+	before  map[string]string // userID -> channel before the command
+	after   map[string]string // userID -> channel the command moved them to
+	mu      sync.Mutex
+}
+
+var (
+	// Latest snapshot per guild, !undo only goes back one step
+	moveSnapshots   = make(map[string]*moveSnapshot)
+	moveSnapshotsMu sync.Mutex
+)
+
+func undoWindow() time.Duration {
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	if botConfig.Undo.WindowSeconds > 0 {
+		return time.Duration(botConfig.Undo.WindowSeconds) * time.Second
+	}
+	return defaultUndoWindow
+}
+
+// Record the guild's voice states before a command starts moving people
+func takeMoveSnapshot(discord *discordgo.Session, guildID, command string) *moveSnapshot {
+	snapshot := &moveSnapshot{
+		command: command,
+		taken:   time.Now(),
+		before:  make(map[string]string),
+		after:   make(map[string]string),
+	}
+
+	guild, err := discord.State.Guild(guildID)
+	if err != nil {
+		log.Printf("Failed to snapshot voice states for %s: %v", guildID, err)
+		return snapshot
+	}
+
+	discord.State.RLock()
+	for _, vs := range guild.VoiceStates {
+		snapshot.before[vs.UserID] = vs.ChannelID
+	}
+	discord.State.RUnlock()
+	return snapshot
+}
+
+// Move a member and remember it for !undo
+func (s *moveSnapshot) move(discord *discordgo.Session, guildID, userID, channelID string) error {
+	if err := discord.GuildMemberMove(guildID, userID, &channelID); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.after[userID] = channelID
+	s.mu.Unlock()
+	return nil
+}
+
+// Keep the snapshot as the guild's undo step when anyone was moved
+func (s *moveSnapshot) save(guildID string) {
+	s.mu.Lock()
+	moved := len(s.after)
+	s.mu.Unlock()
+	if moved == 0 {
+		return
+	}
+
+	moveSnapshotsMu.Lock()
+	moveSnapshots[guildID] = s
+	moveSnapshotsMu.Unlock()
+}
+
+// Take the guild's undo step, nil when there is none or it expired
+func popMoveSnapshot(guildID string) *moveSnapshot {
+	moveSnapshotsMu.Lock()
+	defer moveSnapshotsMu.Unlock()
+
+	snapshot := moveSnapshots[guildID]
+	delete(moveSnapshots, guildID)
+	if snapshot == nil || time.Since(snapshot.taken) > undoWindow() {
+		return nil
+	}
+	return snapshot
+}
+
+// Put everyone the last move touched back where they were, unless they
+// have moved on their own since
+func undoHandler(cmd *CommandContext) {
+	discord := cmd.Discord
+	guildID := cmd.GuildID
+
+	snapshot := popMoveSnapshot(guildID)
+	if snapshot == nil {
+		cmd.Replyf("❌ Nothing to undo, moves can only be undone for %s.", undoWindow())
+		return
+	}
+
+	var movedCount int
+	var skipped []skippedMove
+	for userID, movedTo := range snapshot.after {
+		current, err := discord.State.VoiceState(guildID, userID)
+		switch {
+		case err != nil || current == nil || current.ChannelID == "":
+			skipped = append(skipped, skippedMove{userID: userID, reason: "left voice"})
+			continue
+		case current.ChannelID != movedTo:
+			skipped = append(skipped, skippedMove{userID: userID, reason: "moved since"})
+			continue
+		}
+		if reason := moveProtection(discord, guildID, userID, current.ChannelID); reason != "" {
+			skipped = append(skipped, skippedMove{userID: userID, reason: reason})
+			continue
+		}
+
+		previous, ok := snapshot.before[userID]
+		if !ok || previous == "" {
+			continue
+		}
+		if err := discord.GuildMemberMove(guildID, userID, &previous); err != nil {
+			log.Printf("Failed to move user %s back: %v", userID, err)
+			continue
+		}
+		movedCount++
+	}
+
+	cmd.Replyf("↩️ Undid %s%s, moved %d user(s) back.%s", cmd.Prefix, snapshot.command, movedCount, skippedSummary(skipped))
+}
@@ -6,6 +6,10 @@ import (
 	"github.com/bwmarrin/discordgo"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // get all the guild's voice channels
@@ -248,3 +252,50 @@ func getUserDisplayName(s *discordgo.Session, guildID, userID string) string {
 	}
 	return member.User.Username
 }
+
+// Parse "ss", "mm:ss" or "hh:mm:ss" into a duration
+func parseTimestamp(value string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", value)
+	}
+
+	var seconds int
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid timestamp %q", value)
+		}
+		seconds = seconds*60 + n
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// Format a duration as mm:ss, or h:mm:ss when it is an hour or longer
+func formatTimestamp(d time.Duration) string {
+	total := int(d.Seconds())
+	hours, minutes, seconds := total/3600, (total%3600)/60, total%60
+	if hours > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", hours, minutes, seconds)
+	}
+	return fmt.Sprintf("%02d:%02d", minutes, seconds)
+}
+
+// Fit text into a Discord message by dropping whole lines from the end and
+// adding "...". A single line that is still too long is cut on a rune
+// boundary, so multi-byte titles and emoji never split
+func truncateLines(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	const ellipsis = "..."
+
+	end := limit - len(ellipsis)
+	if i := strings.LastIndexByte(text[:end], '\n'); i > 0 {
+		return text[:i+1] + ellipsis
+	}
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return text[:end] + ellipsis
+}
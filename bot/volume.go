@@ -0,0 +1,142 @@
+package bot
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultVolume = 100
+	maxVolume     = 200
+
+	// EBU R128 target: -16 LUFS integrated, -1.5 dBTP true peak
+	loudnormFilter = "loudnorm=I=-16:TP=-1.5:LRA=11"
+)
+
+// Get the playback settings for a guild, falling back to the defaults
+func getAudioSettings(guildID string) AudioSettings {
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	settings, ok := botConfig.Audio[guildID]
+	if !ok {
+		return AudioSettings{Volume: defaultVolume}
+	}
+	return settings
+}
+
+// Apply a change to a guild's playback settings and persist the config
+func updateAudioSettings(guildID string, update func(*AudioSettings)) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	settings, ok := botConfig.Audio[guildID]
+	if !ok {
+		settings = AudioSettings{Volume: defaultVolume}
+	}
+	update(&settings)
+	botConfig.Audio[guildID] = settings
+
+	return saveBotConfig()
+}
+
+// Scale PCM samples in place by volume percent, clipping at the int16 range
+func applyVolume(pcm []int16, volume int) {
+	if volume == 100 {
+		return
+	}
+
+	gain := float64(volume) / 100
+	for i, sample := range pcm {
+		scaled := float64(sample) * gain
+		pcm[i] = int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, scaled)))
+	}
+}
+
+// ffmpeg audio filters for the guild's settings, empty when none apply.
+// The effect runs first so normalization evens out its loudness too
+func audioFilters(guildID string) []string {
+	settings := getAudioSettings(guildID)
+
+	var filters []string
+	if settings.Effect != "" {
+		if graph, ok := effectPreset(settings.Effect); ok {
+			filters = append(filters, graph)
+		}
+	}
+	if settings.Normalize {
+		filters = append(filters, loudnormFilter)
+	}
+	return filters
+}
+
+// Guild filters plus the current track's own gain, which goes first so
+// normalization still has the final say
+func (vs *VoiceSession) trackFilters() []string {
+	filters := audioFilters(vs.guildID)
+
+	vs.mu.RLock()
+	var offset float64
+	if vs.current != nil {
+		offset = vs.current.VolumeOffsetDB
+	}
+	vs.mu.RUnlock()
+
+	if offset != 0 {
+		filters = append([]string{fmt.Sprintf("volume=%.1fdB", offset)}, filters...)
+	}
+	return filters
+}
+
+func volumeHandler(cmd *CommandContext) {
+	guildID := cmd.GuildID
+	arg := strings.ToLower(cmd.String("level"))
+
+	if arg == "" {
+		settings := getAudioSettings(guildID)
+		normalize := "off"
+		if settings.Normalize {
+			normalize = "on"
+		}
+		cmd.Replyf("🔊 Volume is **%d%%**, loudness normalization is **%s**.", settings.Volume, normalize)
+		return
+	}
+
+	if arg == "normalize" {
+		var enabled bool
+		switch strings.ToLower(cmd.String("state")) {
+		case "on":
+			enabled = true
+		case "off":
+			enabled = false
+		default:
+			cmd.UsageError("normalize needs on or off")
+			return
+		}
+
+		if err := updateAudioSettings(guildID, func(s *AudioSettings) { s.Normalize = enabled }); err != nil {
+			cmd.Reply("❌ Failed to save audio settings: " + err.Error())
+			return
+		}
+		if enabled {
+			cmd.Reply("📏 Loudness normalization enabled, it applies from the next track.")
+		} else {
+			cmd.Reply("📏 Loudness normalization disabled, it applies from the next track.")
+		}
+		return
+	}
+
+	volume, err := strconv.Atoi(strings.TrimSuffix(arg, "%"))
+	if err != nil || volume < 0 || volume > maxVolume || cmd.Has("state") {
+		cmd.UsageError(fmt.Sprintf("volume must be between 0 and %d", maxVolume))
+		return
+	}
+
+	if err := updateAudioSettings(guildID, func(s *AudioSettings) { s.Volume = volume }); err != nil {
+		cmd.Reply("❌ Failed to save audio settings: " + err.Error())
+		return
+	}
+	cmd.Replyf("🔊 Volume set to **%d%%**.", volume)
+}
@@ -0,0 +1,304 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	youtubeModeStream   = "stream"
+	youtubeModeDownload = "download"
+
+	maxPlaylistEntries = 100
+)
+
+// Returned when yt-dlp produced no audio, the caller falls back to downloading
+var errStreamUnavailable = errors.New("youtube stream unavailable")
+
+// A running yt-dlp process writing audio to stdout
+type ytStream struct {
+	cmd      *exec.Cmd
+	stdout   io.ReadCloser
+	stderr   bytes.Buffer
+	waitOnce sync.Once
+	waitErr  error
+}
+
+func youtubeMode() string {
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	if botConfig.YouTube.Mode == youtubeModeDownload {
+		return youtubeModeDownload
+	}
+	return youtubeModeStream
+}
+
+// Start yt-dlp writing the best audio format to stdout, killed with ctx
+func startYTStream(ctx context.Context, url string) (*ytStream, error) {
+	stream := &ytStream{
+		cmd: exec.CommandContext(ctx, "yt-dlp", "-f", "bestaudio/best", "--no-playlist", "--quiet", "--no-warnings", "-o", "-", url),
+	}
+	stream.cmd.Stderr = &stream.stderr
+
+	stdout, err := stream.cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create yt-dlp pipe: %w", err)
+	}
+	stream.stdout = stdout
+
+	if err := stream.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("%w: yt-dlp failed to start: %v", errStreamUnavailable, err)
+	}
+	return stream, nil
+}
+
+// Wait for yt-dlp to exit on its own and return its status
+func (s *ytStream) wait() error {
+	s.waitOnce.Do(func() {
+		s.waitErr = s.cmd.Wait()
+	})
+	return s.waitErr
+}
+
+func (s *ytStream) close() {
+	s.cmd.Process.Kill()
+	s.wait()
+}
+
+// Stop yt-dlp and describe why it produced nothing
+func (s *ytStream) failure() error {
+	s.close()
+
+	lines := strings.Split(strings.TrimSpace(s.stderr.String()), "\n")
+	reason := lines[len(lines)-1]
+	if reason == "" {
+		reason = "no audio received"
+	}
+	log.Printf("yt-dlp stream failed: %s", s.stderr.String())
+	return fmt.Errorf("%w: %s", errStreamUnavailable, reason)
+}
+
+// Queue a YouTube link, expanding playlists into one track per entry.
+// The operation context only covers the metadata lookup and eager downloads,
+// !kill stops queued playback through the voice session
+func playYouTube(ctx context.Context, discord *discordgo.Session, channelID, guildID, requester, url string) {
+	select {
+	case <-ctx.Done():
+		discord.ChannelMessageSend(channelID, "❌ YouTube request cancelled.")
+		return
+	default:
+	}
+
+	info, err := fetchYTInfo(ctx, url)
+	if err != nil {
+		if ctx.Err() != nil {
+			discord.ChannelMessageSend(channelID, "❌ YouTube request cancelled.")
+		} else {
+			log.Printf("yt-dlp metadata error for %s: %v", url, err)
+			discord.ChannelMessageSend(channelID, "❌ Failed to read YouTube link.")
+		}
+		return
+	}
+
+	queueYTInfo(ctx, discord, channelID, guildID, requester, info)
+}
+
+// Queue a video or every entry of a playlist using the configured mode
+func queueYTInfo(ctx context.Context, discord *discordgo.Session, channelID, guildID, requester string, info *ytInfo) {
+	session, ok := getVoiceSession(guildID)
+	if !ok {
+		discord.ChannelMessageSend(channelID, "❌ Not connected to a voice channel.")
+		return
+	}
+
+	stream := youtubeMode() == youtubeModeStream
+
+	if len(info.Entries) > 0 {
+		entries := info.Entries
+		if len(entries) > maxPlaylistEntries {
+			entries = entries[:maxPlaylistEntries]
+		}
+
+		var total time.Duration
+		for i := range entries {
+			track := newYTTrack(&entries[i], channelID, requester)
+			track.Stream = stream
+			total += track.Duration
+			session.enqueue(track)
+		}
+
+		response := fmt.Sprintf("📃 Queued **%d** track(s) from **%s**", len(entries), info.Title)
+		if total > 0 {
+			response += fmt.Sprintf(" (%s total)", formatTimestamp(total))
+		}
+		if len(info.Entries) > len(entries) {
+			response += fmt.Sprintf(", skipped %d past the %d track limit", len(info.Entries)-len(entries), maxPlaylistEntries)
+		}
+		discord.ChannelMessageSend(channelID, response+".")
+		return
+	}
+
+	track := newYTTrack(info, channelID, requester)
+	if !stream {
+		downloadAndPlayYT(ctx, discord, guildID, track)
+		return
+	}
+	track.Stream = true
+	enqueueTrack(discord, session, track)
+}
+
+// Stream a queued YouTube track, downloading it instead if yt-dlp can't stream
+func playYTStream(ctx context.Context, session *VoiceSession, track *Track) {
+	discord := session.discord
+
+	session.mu.RLock()
+	vc := session.connection
+	session.mu.RUnlock()
+
+	if vc == nil {
+		return
+	}
+
+	// Keep a copy of the stream so loop modes replay it without fetching again
+	cache := &streamCache{filename: fmt.Sprintf("yt_stream_%d_%d.audio", time.Now().Unix(), tempFileID())}
+
+	err := playSource(ctx, session, vc, pcmSource{url: track.URL, cache: cache}, discord, track.ChannelID)
+	if err == nil {
+		if cache.complete {
+			addTempFile(session.guildID, cache.filename)
+			session.mu.Lock()
+			track.Filename = cache.filename
+			track.Stream = false
+			session.mu.Unlock()
+		} else {
+			os.Remove(cache.filename)
+		}
+		return
+	}
+	os.Remove(cache.filename)
+
+	if !errors.Is(err, errStreamUnavailable) {
+		discord.ChannelMessageSend(track.ChannelID, "❌ Playback failed: "+err.Error())
+		log.Printf("Streaming %s failed in guild %s: %v", track.URL, session.guildID, err)
+		return
+	}
+
+	discord.ChannelMessageSend(track.ChannelID, "⚠️ Streaming failed, downloading the audio instead...")
+	playYTDownload(ctx, session, track)
+}
+
+// Download a queued YouTube track and play the file
+func playYTDownload(ctx context.Context, session *VoiceSession, track *Track) {
+	discord := session.discord
+
+	filename, err := downloadYT(ctx, session.guildID, track.URL)
+	if err != nil {
+		if ctx.Err() == nil {
+			discord.ChannelMessageSend(track.ChannelID, "❌ "+err.Error())
+		}
+		return
+	}
+
+	// From here on the track is a regular temp file the queue cleans up
+	session.mu.Lock()
+	track.Filename = filename
+	track.Stream = false
+	session.mu.Unlock()
+	playMP3(ctx, session, filename, discord, track.ChannelID)
+}
+
+// Fields we use from yt-dlp's -J output, for a video, a playlist or a flat
+// playlist entry
+type ytInfo struct {
+	Type       string  `json:"_type"`
+	ID         string  `json:"id"`
+	Title      string  `json:"title"`
+	URL        string  `json:"url"`
+	WebpageURL string  `json:"webpage_url"`
+	Uploader   string  `json:"uploader"`
+	Channel    string  `json:"channel"`
+	Duration   float64 `json:"duration"`
+	Thumbnail  string  `json:"thumbnail"`
+	Thumbnails []struct {
+		URL string `json:"url"`
+	} `json:"thumbnails"`
+	Entries []ytInfo `json:"entries"`
+}
+
+// Ask yt-dlp for a video's or playlist's metadata without downloading audio.
+// Playlists come back flat so large ones stay fast
+func fetchYTInfo(ctx context.Context, target string) (*ytInfo, error) {
+	cmd := exec.CommandContext(ctx, "yt-dlp", "-J", "--flat-playlist", "--no-warnings", target)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("yt-dlp failed: %w (output: %s)", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var info ytInfo
+	if err := json.Unmarshal(output, &info); err != nil {
+		return nil, fmt.Errorf("failed to decode yt-dlp JSON: %w", err)
+	}
+	return &info, nil
+}
+
+func (info *ytInfo) pageURL() string {
+	switch {
+	case info.WebpageURL != "":
+		return info.WebpageURL
+	case strings.HasPrefix(info.URL, "http"):
+		return info.URL
+	default:
+		return "https://www.youtube.com/watch?v=" + info.ID
+	}
+}
+
+func (info *ytInfo) uploaderName() string {
+	if info.Uploader != "" {
+		return info.Uploader
+	}
+	return info.Channel
+}
+
+// Flat entries only carry a thumbnail list, the last one is the largest
+func (info *ytInfo) thumbnailURL() string {
+	if info.Thumbnail != "" {
+		return info.Thumbnail
+	}
+	if len(info.Thumbnails) > 0 {
+		return info.Thumbnails[len(info.Thumbnails)-1].URL
+	}
+	return ""
+}
+
+func newYTTrack(info *ytInfo, channelID, requester string) *Track {
+	title := info.Title
+	if title == "" {
+		title = info.pageURL()
+	}
+
+	return &Track{
+		Title:     title,
+		URL:       info.pageURL(),
+		ChannelID: channelID,
+		Requester: requester,
+		Uploader:  info.uploaderName(),
+		Duration:  time.Duration(info.Duration * float64(time.Second)),
+		Thumbnail: info.thumbnailURL(),
+	}
+}
@@ -0,0 +1,118 @@
+package bot
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	searchResultCount = 5
+	searchResultTTL   = 2 * time.Minute
+)
+
+// Results of a user's last !ytsearch, waiting for !pick
+type pendingSearch struct {
+	results []ytInfo
+	expires time.Time
+}
+
+var (
+	pendingSearches   = make(map[string]*pendingSearch) // guildID:userID -> results
+	pendingSearchesMu sync.Mutex
+)
+
+func searchKey(guildID, userID string) string {
+	return guildID + ":" + userID
+}
+
+func ytSearchHandler(cmd *CommandContext) {
+	terms := cmd.String("terms")
+
+	opID := fmt.Sprintf("ytsearch_%s_%d", cmd.GuildID, time.Now().Unix())
+	ctx := createOperationContext(opID)
+	defer removeOperationContext(opID)
+
+	cmd.Reply("🔎 Searching YouTube for *" + terms + "*...")
+
+	info, err := fetchYTInfo(ctx, fmt.Sprintf("ytsearch%d:%s", searchResultCount, terms))
+	if err != nil {
+		if ctx.Err() != nil {
+			cmd.Reply("❌ YouTube search cancelled.")
+		} else {
+			cmd.Reply("❌ YouTube search failed.")
+		}
+		return
+	}
+
+	if len(info.Entries) == 0 {
+		cmd.Reply("📭 No results found.")
+		return
+	}
+
+	pendingSearchesMu.Lock()
+	// Searches nobody picked from would otherwise stay forever
+	now := time.Now()
+	for key, search := range pendingSearches {
+		if now.After(search.expires) {
+			delete(pendingSearches, key)
+		}
+	}
+	pendingSearches[searchKey(cmd.GuildID, cmd.Author.ID)] = &pendingSearch{
+		results: info.Entries,
+		expires: now.Add(searchResultTTL),
+	}
+	pendingSearchesMu.Unlock()
+
+	response := "🔎 **Search results:**\n"
+	for i := range info.Entries {
+		entry := &info.Entries[i]
+		response += fmt.Sprintf("`%d.` %s", i+1, entry.Title)
+		if uploader := entry.uploaderName(); uploader != "" {
+			response += " — " + uploader
+		}
+		if entry.Duration > 0 {
+			response += fmt.Sprintf(" (%s)", formatTimestamp(time.Duration(entry.Duration*float64(time.Second))))
+		}
+		response += "\n"
+	}
+	response += fmt.Sprintf("Reply with `%spick <number>` to queue one.", cmd.Prefix)
+
+	response = truncateLines(response, 2000)
+	cmd.Reply(response)
+}
+
+func ytPickHandler(cmd *CommandContext) {
+	choice := cmd.Int("number")
+	key := searchKey(cmd.GuildID, cmd.Author.ID)
+
+	pendingSearchesMu.Lock()
+	search, ok := pendingSearches[key]
+	if ok && time.Now().After(search.expires) {
+		delete(pendingSearches, key)
+		ok = false
+	}
+	if ok && choice >= 1 && choice <= len(search.results) {
+		delete(pendingSearches, key)
+	}
+	pendingSearchesMu.Unlock()
+
+	if !ok {
+		cmd.Replyf("❌ You have no recent search, use %sytsearch <terms> first.", cmd.Prefix)
+		return
+	}
+	if choice < 1 || choice > len(search.results) {
+		cmd.Replyf("❌ Pick a number between 1 and %d.", len(search.results))
+		return
+	}
+
+	if !botConnect(cmd) {
+		return
+	}
+
+	opID := fmt.Sprintf("youtube_%s_%d", cmd.GuildID, time.Now().Unix())
+	ctx := createOperationContext(opID)
+	defer removeOperationContext(opID)
+
+	queueYTInfo(ctx, cmd.Discord, cmd.ChannelID, cmd.GuildID, cmd.Author.ID, &search.results[choice-1])
+}